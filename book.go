@@ -0,0 +1,131 @@
+package exceltesting
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// bookSource は Load、Compare の対象のExcelブックの読み込み元です。
+type bookSource struct {
+	path   string
+	fsys   fs.FS
+	reader io.Reader
+	book   *excelize.File
+}
+
+// onDisk はOSのファイルシステム上のパスからブックを開くかどうかを返します。
+func (s bookSource) onDisk() bool {
+	return s.fsys == nil && s.reader == nil && s.book == nil
+}
+
+// open はExcelブックを開きます。
+// 開いたブック、io.Reader、fs.FS、OSのファイルシステムの順に優先します。
+// 戻り値の関数でブックを閉じます。開いたブックを指定した場合は閉じません。
+func (s bookSource) open() (*excelize.File, func() error, error) {
+	switch {
+	case s.book != nil:
+		return s.book, func() error { return nil }, nil
+	case s.reader != nil:
+		f, err := excelize.OpenReader(s.reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("excelize.OpenReader: %w", err)
+		}
+		return f, f.Close, nil
+	case s.fsys != nil:
+		file, err := s.fsys.Open(s.path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open %s: %w", s.path, err)
+		}
+		defer file.Close()
+		f, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("excelize.OpenReader: %w", err)
+		}
+		return f, f.Close, nil
+	}
+	f, err := excelize.OpenFile(s.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("excelize.OpenFile: %w", err)
+	}
+	return f, f.Close, nil
+}
+
+// dumpCSV はブックの全シートをCSVにDumpします。
+// OSのファイルシステム以外から開いたブックは出力先を決められないため、outDir の指定が必要です。
+// CSVファイル名には TargetBookPath をブック名として用います。
+func (w *workbook) dumpCSV(outDir string) error {
+	if w.src.onDisk() {
+		return dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{w.src.path}, OutputDir: outDir})
+	}
+	if outDir == "" {
+		return errors.New("exceltesing: DumpCSVDir is required to dump csv of a book not opened from the file system")
+	}
+	if w.src.path == "" {
+		return errors.New("exceltesing: TargetBookPath is required as the book name of csv files")
+	}
+	return dumpOpenedBookAsCSV(w.f, w.src.path, outDir)
+}
+
+// workbook は Load、Compare の対象のExcelブックです。
+//
+// OSのファイルシステム上のブックは解析したシートをプロセス内でキャッシュするため、
+// 同じブックを何度読み込んでもファイルを開き直しません。
+// 差分ブックの出力など、ブックそのものが必要な場合のみ file でブックを開きます。
+type workbook struct {
+	src    bookSource
+	parsed *parsedBook
+	f      *excelize.File
+	close  func() error
+}
+
+// openWorkbook はExcelブックを開き、全シートを解析します。
+func openWorkbook(src bookSource) (*workbook, error) {
+	if src.onDisk() {
+		parsed, err := defaultBookCache.load(src.path)
+		if err != nil {
+			return nil, err
+		}
+		return &workbook{src: src, parsed: parsed}, nil
+	}
+
+	f, closeBook, err := src.open()
+	if err != nil {
+		return nil, err
+	}
+	return &workbook{src: src, parsed: parseBook(f), f: f, close: closeBook}, nil
+}
+
+// sheetList はブックのシート名の一覧を返します。
+func (w *workbook) sheetList() []string {
+	return w.parsed.sheetList
+}
+
+// sheet は解析したシートを返します。呼び出し元で変更できるよう、コピーを返します。
+func (w *workbook) sheet(name string) (*sheet, error) {
+	return w.parsed.sheet(name)
+}
+
+// file はブックを返します。キャッシュから解析したシートを取得した場合は、ここでブックを開きます。
+func (w *workbook) file() (*excelize.File, error) {
+	if w.f != nil {
+		return w.f, nil
+	}
+	f, closeBook, err := w.src.open()
+	if err != nil {
+		return nil, err
+	}
+	w.f, w.close = f, closeBook
+	return f, nil
+}
+
+// Close はブックを開いている場合に閉じます。
+func (w *workbook) Close() error {
+	if w.close == nil {
+		return nil
+	}
+	return w.close()
+}
@@ -0,0 +1,144 @@
+package exceltesting
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_bookSource_open(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "load.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := excelize.OpenFile(filepath.Join("testdata", "load.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opened.Close()
+
+	tests := []struct {
+		name    string
+		src     bookSource
+		wantErr bool
+	}{
+		{name: "path", src: bookSource{path: filepath.Join("testdata", "load.xlsx")}},
+		{name: "fs", src: bookSource{path: "load.xlsx", fsys: os.DirFS("testdata")}},
+		{name: "reader", src: bookSource{reader: bytes.NewReader(b)}},
+		{name: "book", src: bookSource{book: opened}},
+		{name: "not found in fs", src: bookSource{path: "notfound.xlsx", fsys: os.DirFS("testdata")}, wantErr: true},
+	}
+	want := opened.GetSheetList()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, closeBook, err := tt.src.open()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer closeBook()
+			if diff := cmp.Diff(want, f.GetSheetList()); diff != "" {
+				t.Errorf("open() sheets mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_workbook_dumpCSV(t *testing.T) {
+	wb, err := openWorkbook(bookSource{path: "load.xlsx", fsys: os.DirFS("testdata")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wb.Close()
+
+	if err := wb.dumpCSV(""); err == nil {
+		t.Errorf("dumpCSV() should return error without output directory")
+	}
+
+	gotDir, wantDir := t.TempDir(), t.TempDir()
+	if err := wb.dumpCSV(gotDir); err != nil {
+		t.Fatalf("dumpCSV() error = %v", err)
+	}
+	if err := dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{filepath.Join("testdata", "load.xlsx")}, OutputDir: wantDir}); err != nil {
+		t.Fatalf("dumpBookAsCSV() error = %v", err)
+	}
+
+	wantFiles, err := os.ReadDir(wantDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wantFiles) == 0 {
+		t.Fatalf("dumpBookAsCSV() should write csv files")
+	}
+	for _, file := range wantFiles {
+		want, err := os.ReadFile(filepath.Join(wantDir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(gotDir, file.Name()))
+		if err != nil {
+			t.Fatalf("dumpCSV() should write %s: %v", file.Name(), err)
+		}
+		if diff := cmp.Diff(string(want), string(got)); diff != "" {
+			t.Errorf("dumpCSV() %s mismatch (-want +got):\n%s", file.Name(), diff)
+		}
+	}
+
+	// fs.FS から開いたブックは、OSのファイルシステム上の testdata/load_v2.xlsx を別のブックとみなさない
+	wb2, err := openWorkbook(bookSource{path: "testdata/load.xlsx", fsys: os.DirFS(".")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wb2.Close()
+	stale := filepath.Join(gotDir, "load_v2_deleted.csv")
+	if err := os.WriteFile(stale, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := wb2.dumpCSV(gotDir); err != nil {
+		t.Fatalf("dumpCSV() error = %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("dumpCSV() should delete %s", stale)
+	}
+}
+
+// testSheet はテスト用に作成するバージョン2.0形式のシートです。
+type testSheet struct {
+	name  string
+	table string
+	cols  []string
+	// 各行の先頭はA列の行番号です
+	data [][]string
+}
+
+// writeTestBook はテスト用のブックを path に作成します。
+func writeTestBook(t *testing.T, path string, sheets []testSheet) {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for _, s := range sheets {
+		f.NewSheet(s.name)
+		rows := [][]string{{s.name}, {s.table}, {"version", "2.0"}, nil, {"項目名"}, append([]string{"項目物理名"}, s.cols...)}
+		rows = append(rows, s.data...)
+		for i, row := range rows {
+			values := make([]any, len(row))
+			for j, v := range row {
+				values[j] = v
+			}
+			if err := f.SetSheetRow(s.name, fmt.Sprintf("A%d", i+1), &values); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	f.DeleteSheet("Sheet1")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+}
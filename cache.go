@@ -0,0 +1,123 @@
+package exceltesting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// defaultBookCache はOSのファイルシステム上のブックを解析した結果のプロセス内のキャッシュです。
+var defaultBookCache = &bookCache{entries: make(map[string]*bookCacheEntry)}
+
+// bookCache は解析したブックを、ファイルのパスをキーにキャッシュします。
+// 更新日時かサイズが変わったファイルは解析し直します。並列に実行するテストから同時に利用できます。
+type bookCache struct {
+	mu      sync.Mutex
+	entries map[string]*bookCacheEntry
+}
+
+type bookCacheEntry struct {
+	modTime time.Time
+	size    int64
+	once    sync.Once
+	book    *parsedBook
+	err     error
+}
+
+// load はブックを解析した結果を返します。キャッシュにない場合はブックを開いて解析します。
+func (c *bookCache) load(path string) (*parsedBook, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("abs path: %w", err)
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[abs]
+	if !ok || !entry.modTime.Equal(fi.ModTime()) || entry.size != fi.Size() {
+		entry = &bookCacheEntry{modTime: fi.ModTime(), size: fi.Size()}
+		c.entries[abs] = entry
+	}
+	c.mu.Unlock()
+
+	// 同じブックを並列に読み込む場合も、解析は1回のみ行う
+	entry.once.Do(func() {
+		f, err := excelize.OpenFile(abs)
+		if err != nil {
+			entry.err = fmt.Errorf("excelize.OpenFile: %w", err)
+			return
+		}
+		defer f.Close()
+		entry.book = parseBook(f)
+	})
+	if entry.err != nil {
+		// 開けなかったブックはキャッシュせずに、次回に開き直す
+		c.mu.Lock()
+		if c.entries[abs] == entry {
+			delete(c.entries, abs)
+		}
+		c.mu.Unlock()
+		return nil, entry.err
+	}
+	return entry.book, nil
+}
+
+// parsedBook はブックの全シートを解析した結果です。
+type parsedBook struct {
+	sheetList []string
+	sheets    map[string]*sheet
+	errs      map[string]error
+}
+
+// parseBook はブックの全シートを解析します。
+// 解析できないシートはエラーを保持し、Load や Compare の対象になった場合に報告します。
+func parseBook(f *excelize.File) *parsedBook {
+	b := &parsedBook{
+		sheetList: f.GetSheetList(),
+		sheets:    make(map[string]*sheet),
+		errs:      make(map[string]error),
+	}
+	for _, name := range b.sheetList {
+		s, err := parseSheet(f, name)
+		if err != nil {
+			b.errs[name] = err
+			continue
+		}
+		b.sheets[name] = s
+	}
+	return b
+}
+
+// sheet は解析したシートのコピーを返します。
+func (b *parsedBook) sheet(name string) (*sheet, error) {
+	if err, ok := b.errs[name]; ok {
+		return nil, err
+	}
+	s, ok := b.sheets[name]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s does not exist", name)
+	}
+	return s.deepCopy(), nil
+}
+
+// deepCopy はシートのコピーを作成します。
+func (s *sheet) deepCopy() *sheet {
+	cp := *s
+	cp.logicalColumns = append([]string(nil), s.logicalColumns...)
+	cp.labels = append([]string(nil), s.labels...)
+	cp.rowNums = append([]int(nil), s.rowNums...)
+	cp.options = make(map[string]string, len(s.options))
+	for k, v := range s.options {
+		cp.options[k] = v
+	}
+	t := s.table.DeepCopy()
+	cp.table = &t
+	return &cp
+}
@@ -0,0 +1,69 @@
+package exceltesting
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_bookCache_load(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "load.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "load.xlsx")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &bookCache{entries: make(map[string]*bookCacheEntry)}
+
+	var wg sync.WaitGroup
+	books := make([]*parsedBook, 8)
+	for i := range books {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			books[i], _ = c.load(path)
+		}(i)
+	}
+	wg.Wait()
+	for i, book := range books {
+		if book == nil || book != books[0] {
+			t.Fatalf("load() #%d should return the cached book", i)
+		}
+	}
+
+	sheetName := books[0].sheetList[0]
+	s, err := books[0].sheet(sheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.table.data[0][0] = "changed"
+	s.options["mode"] = "changed"
+	s2, err := books[0].sheet(sheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.table.data[0][0] == "changed" || s2.options["mode"] == "changed" {
+		t.Errorf("sheet() should return a copy of the cached sheet")
+	}
+
+	modTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := c.load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == books[0] {
+		t.Errorf("load() should parse the book again when the file is modified")
+	}
+
+	if _, err := c.load(filepath.Join(t.TempDir(), "notfound.xlsx")); err == nil {
+		t.Errorf("load() should return error for a missing file")
+	}
+}
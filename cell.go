@@ -0,0 +1,71 @@
+package exceltesting
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CellNormalization はExcelのデータ行のセルの値を、投入や比較に用いる前に正規化する方法です。
+// Load と Compare で同じ方法を指定することで、同じシートを同じ値として扱えます。
+type CellNormalization string
+
+const (
+	// CellNormalizationPreserve はセルの値をそのまま用います(デフォルト)
+	CellNormalizationPreserve CellNormalization = "preserve"
+	// CellNormalizationTrim はセルの値の前後の空白(全角空白を含む)を取り除きます
+	CellNormalizationTrim CellNormalization = "trim"
+	// CellNormalizationNFKC はセルの値をUnicode正規化(NFKC)した上で、前後の空白を取り除きます
+	// 全角英数字は半角英数字に、半角カタカナは全角カタカナになります
+	CellNormalizationNFKC CellNormalization = "nfkc"
+)
+
+const (
+	rawCellPrefix = "<RAW:"
+	rawCellSuffix = ">"
+)
+
+// validate は正規化の方法が正しいか検証します。
+func (n CellNormalization) validate() error {
+	switch n {
+	case "", CellNormalizationPreserve, CellNormalizationTrim, CellNormalizationNFKC:
+		return nil
+	}
+	return fmt.Errorf("unknown cell normalization %q", n)
+}
+
+// normalizeCell はセルの値を n に従って正規化します。
+//
+// <RAW: abc > のように記載したセルは、正規化せずに記法の内側の値をそのまま用います。
+// 前後の空白に意味がある値や、条件による比較の記法と同じ文字列を記載する場合に利用します。
+func normalizeCell(cell string, n CellNormalization) string {
+	if v, ok := rawCell(cell); ok {
+		return v
+	}
+	switch n {
+	case CellNormalizationTrim:
+		return strings.TrimFunc(cell, unicode.IsSpace)
+	case CellNormalizationNFKC:
+		return strings.TrimFunc(norm.NFKC.String(cell), unicode.IsSpace)
+	}
+	return cell
+}
+
+// rawCell は <RAW:...> の記法で記載されたセルの場合に、記法の内側の値を返します。
+func rawCell(cell string) (string, bool) {
+	if strings.HasPrefix(cell, rawCellPrefix) && strings.HasSuffix(cell, rawCellSuffix) && len(cell) >= len(rawCellPrefix)+len(rawCellSuffix) {
+		return cell[len(rawCellPrefix) : len(cell)-len(rawCellSuffix)], true
+	}
+	return "", false
+}
+
+// normalizeCells はテーブルのデータ行の全てのセルを n に従って正規化します。
+func normalizeCells(t *table, n CellNormalization) {
+	for i, row := range t.data {
+		for j, cell := range row {
+			t.data[i][j] = normalizeCell(cell, n)
+		}
+	}
+}
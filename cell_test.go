@@ -0,0 +1,62 @@
+package exceltesting
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_normalizeCell(t *testing.T) {
+	tests := []struct {
+		name string
+		cell string
+		n    CellNormalization
+		want string
+	}{
+		{name: "default preserves spaces", cell: " abc　", n: "", want: " abc　"},
+		{name: "preserve", cell: " ＡＢＣ ", n: CellNormalizationPreserve, want: " ＡＢＣ "},
+		{name: "trim half-width and full-width spaces", cell: "　 abc \t", n: CellNormalizationTrim, want: "abc"},
+		{name: "trim keeps inner spaces", cell: " a b ", n: CellNormalizationTrim, want: "a b"},
+		{name: "trim keeps full-width letters", cell: "ＡＢＣ", n: CellNormalizationTrim, want: "ＡＢＣ"},
+		{name: "nfkc full-width letters", cell: "ＡＢＣ１２３", n: CellNormalizationNFKC, want: "ABC123"},
+		{name: "nfkc half-width katakana", cell: "ｶﾞｷﾞ", n: CellNormalizationNFKC, want: "ガギ"},
+		{name: "nfkc trims", cell: "　ｱ　", n: CellNormalizationNFKC, want: "ア"},
+		{name: "raw keeps spaces", cell: "<RAW: abc >", n: CellNormalizationTrim, want: " abc "},
+		{name: "raw skips nfkc", cell: "<RAW:ＡＢＣ>", n: CellNormalizationNFKC, want: "ＡＢＣ"},
+		{name: "raw escapes matcher", cell: "<RAW:<ANY>>", n: CellNormalizationPreserve, want: "<ANY>"},
+		{name: "raw empty", cell: "<RAW:>", n: CellNormalizationPreserve, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeCell(tt.cell, tt.n)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("normalizeCell() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_extractMatchers_cellNormalization(t *testing.T) {
+	tbl := &table{
+		name:    "company",
+		columns: []string{"id", "name", "note"},
+		data: [][]string{
+			{" 1 ", "ＡＢＣ", "<RAW:<ANY>>"},
+			{"2", "<ANY>", "<RAW: x >"},
+		},
+	}
+	got, matchers, err := extractMatchers(tbl, CellNormalizationNFKC)
+	if err != nil {
+		t.Fatalf("extractMatchers() error = %v", err)
+	}
+	want := [][]string{
+		{"1", "ABC", "<ANY>"},
+		{"2", "", " x "},
+	}
+	if diff := cmp.Diff(want, got.data); diff != "" {
+		t.Errorf("extractMatchers() data mismatch (-want +got):\n%s", diff)
+	}
+	if matchers[0] != nil || matchers[1][1] == nil || matchers[1][2] != nil {
+		t.Errorf("extractMatchers() matchers = %v", matchers)
+	}
+}
@@ -0,0 +1,14 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/future-architect/go-exceltesting"
+)
+
+func DumpCSV(r exceltesting.DumpRequest) error {
+	if err := exceltesting.DumpBookAsCSV(r); err != nil {
+		return fmt.Errorf("dump csv: %w", err)
+	}
+	return nil
+}
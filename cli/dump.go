@@ -18,6 +18,8 @@ import (
 
 const DefaultColumnCnt = 32
 
+// query は指定されたテーブルの定義を取得します。
+// パーティションテーブルは1つのテーブルとして扱い、パーティションはテーブル名を明示した場合のみ対象とします。
 var query = `SELECT tab.relname        AS table_name
 				 , tabdesc.description AS table_description
 				 , col.column_name
@@ -25,22 +27,21 @@ var query = `SELECT tab.relname        AS table_name
 				 , col.data_type
 				 , col.is_nullable
 				 , col.column_default
-			FROM pg_stat_user_tables tab
+			FROM pg_class tab
+					 INNER JOIN pg_namespace ns
+								ON tab.relnamespace = ns.oid
+									AND ns.nspname = current_schema()
 					 LEFT OUTER JOIN pg_description tabdesc
-								ON tab.relid = tabdesc.objoid
+								ON tab.oid = tabdesc.objoid
 									AND tabdesc.objsubid = '0'
 					 LEFT OUTER JOIN information_schema.columns col
 								ON tab.relname = col.table_name
-									AND tab.schemaname = current_schema()
+									AND col.table_schema = current_schema()
 					 LEFT OUTER JOIN pg_description coldesc
-								ON tab.relid = coldesc.objoid
+								ON tab.oid = coldesc.objoid
 									AND col.ordinal_position = coldesc.objsubid
-			         LEFT OUTER JOIN pg_class pc
-                        ON tab.relid = pc.oid
 			WHERE exists(select 1 FROM tmp_exceltesting_dump_table_name WHERE tab.relname = name)
-				AND	tab.schemaname = current_schema()
-				AND col.table_schema = current_schema()
-				AND pc.relispartition = false
+				AND tab.relkind IN ('r', 'p')
 			ORDER BY tab.relname
 				   , col.ordinal_position
 			;
@@ -53,22 +54,22 @@ var queryAll = `SELECT tab.relname        AS table_name
 				 , col.data_type
 				 , col.is_nullable
 				 , col.column_default
-			FROM pg_stat_user_tables tab
+			FROM pg_class tab
+					 INNER JOIN pg_namespace ns
+								ON tab.relnamespace = ns.oid
+									AND ns.nspname = current_schema()
 					 LEFT OUTER JOIN pg_description tabdesc
-								ON tab.relid = tabdesc.objoid
+								ON tab.oid = tabdesc.objoid
 									AND tabdesc.objsubid = '0'
 					 LEFT OUTER JOIN information_schema.columns col
 								ON tab.relname = col.table_name
-									AND tab.schemaname = current_schema()
+									AND col.table_schema = current_schema()
 					 LEFT OUTER JOIN pg_description coldesc
-								ON tab.relid = coldesc.objoid
+								ON tab.oid = coldesc.objoid
 									AND col.ordinal_position = coldesc.objsubid
-			         LEFT OUTER JOIN pg_class pc
-                        ON tab.relid = pc.oid
 			WHERE
-					tab.schemaname = current_schema()
-				AND col.table_schema = current_schema()
-			    AND pc.relispartition = false
+					tab.relkind IN ('r', 'p')
+			    AND tab.relispartition = false
 			ORDER BY tab.relname
 				   , col.ordinal_position
 			;
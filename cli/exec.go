@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/future-architect/go-exceltesting"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+func Exec(dbSource string, files []string) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	db, err := sql.Open("pgx", dbSource)
+	if err != nil {
+		return fmt.Errorf("postgres oepn: %w", err)
+	}
+	defer db.Close()
+
+	for _, file := range files {
+		if err := exceltesting.ExecSQLFile(ctx, db, file); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+	}
+
+	return nil
+}
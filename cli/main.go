@@ -27,10 +27,27 @@ var (
 	loadFile                        = loadCommand.Arg("file", "Target excel file path (e.g. input.xlsx)").Required().NoEnvar().ExistingFile()
 	enableAutoCompleteNotNullColumn = loadCommand.Flag("enableAutoCompleteNotNullColumn", "Enable auto insert to not null columns if excel the cell is undefined").NoEnvar().Bool()
 	enableDumpCSVLoad               = loadCommand.Flag("enableDumpCSV", "Enable excel file dump to csv for code review or version history").NoEnvar().Bool()
+	dumpCSVDirLoad                  = loadCommand.Flag("dumpCSVDir", "Output directory of csv files (default: csv directory next to the excel file)").NoEnvar().String()
+	cellNormalizationLoad           = loadCommand.Flag("cellNormalization", "Normalization of cell values (preserve, trim, nfkc)").NoEnvar().Default("preserve").Enum("preserve", "trim", "nfkc")
 
 	compareCommand       = app.Command("compare", "Compare database to excel file")
 	compareFile          = compareCommand.Arg("file", "Target excel file path (e.g. want.xlsx)").Required().NoEnvar().ExistingFile()
 	enableDumpCSVCompare = compareCommand.Flag("enableDumpCSV", "Enable excel file dump to csv for code review or version history").NoEnvar().Bool()
+	dumpCSVDirCompare    = compareCommand.Flag("dumpCSVDir", "Output directory of csv files (default: csv directory next to the excel file)").NoEnvar().String()
+	htmlReportCompare    = compareCommand.Flag("htmlReport", "Write html report of the comparison to this path").NoEnvar().String()
+	diffBookCompare      = compareCommand.Flag("diffBook", "Write a copy of the excel file with mismatched cells highlighted to this path on failure").NoEnvar().String()
+	cellNormalizationCmp = compareCommand.Flag("cellNormalization", "Normalization of expected cell values (preserve, trim, nfkc)").NoEnvar().Default("preserve").Enum("preserve", "trim", "nfkc")
+
+	csvCommand = app.Command("csv", "Dump excel files to csv for code review or version history")
+	csvFiles   = csvCommand.Arg("files", "Target excel file paths (e.g. testdata/load.xlsx)").Required().NoEnvar().ExistingFiles()
+	csvOutDir  = csvCommand.Flag("out", "Output directory of csv files (default: csv directory next to the excel file)").NoEnvar().String()
+	csvCheck   = csvCommand.Flag("check", "Check csv files are up to date instead of writing them").NoEnvar().Bool()
+
+	execCommand = app.Command("exec", "Execute sql script files (e.g. DDL) in a transaction per file")
+	execFiles   = execCommand.Arg("files", "Target sql file paths (e.g. testdata/schema/ddl.sql)").Required().NoEnvar().ExistingFiles()
+
+	textconvCommand = app.Command("textconv", "Print excel file as line-oriented text for git diff textconv")
+	textconvFile    = textconvCommand.Arg("file", "Target excel file path (e.g. testdata/load.xlsx)").Required().NoEnvar().ExistingFile()
 )
 
 func Main() {
@@ -47,15 +64,32 @@ func Main() {
 			TargetBookPath:                  *loadFile,
 			EnableAutoCompleteNotNullColumn: *enableAutoCompleteNotNullColumn,
 			EnableDumpCSV:                   *enableDumpCSVLoad,
+			DumpCSVDir:                      *dumpCSVDirLoad,
+			CellNormalization:               exceltesting.CellNormalization(*cellNormalizationLoad),
 		}
 		err = Load(*source, req)
 	case compareCommand.FullCommand():
 		req := exceltesting.CompareRequest{
-			TargetBookPath: *compareFile,
-			SheetPrefix:    "",
-			EnableDumpCSV:  *enableDumpCSVCompare,
+			TargetBookPath:    *compareFile,
+			SheetPrefix:       "",
+			CellNormalization: exceltesting.CellNormalization(*cellNormalizationCmp),
+			EnableDumpCSV:     *enableDumpCSVCompare,
+			DumpCSVDir:        *dumpCSVDirCompare,
+			DiffBookPath:      *diffBookCompare,
+			HTMLReportPath:    *htmlReportCompare,
 		}
 		err = Compare(*source, req)
+	case csvCommand.FullCommand():
+		req := exceltesting.DumpRequest{
+			TargetBookPaths: *csvFiles,
+			OutputDir:       *csvOutDir,
+			Check:           *csvCheck,
+		}
+		err = DumpCSV(req)
+	case execCommand.FullCommand():
+		err = Exec(*source, *execFiles)
+	case textconvCommand.FullCommand():
+		err = Textconv(*textconvFile)
 	}
 	if err != nil {
 		_, _ = color.New(color.FgHiRed).Fprintln(os.Stderr, err.Error())
@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/future-architect/go-exceltesting"
+)
+
+func Textconv(targetFile string) error {
+	if err := exceltesting.Textconv(os.Stdout, targetFile); err != nil {
+		return fmt.Errorf("textconv: %w", err)
+	}
+	return nil
+}
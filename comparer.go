@@ -0,0 +1,90 @@
+package exceltesting
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// ValueComparer は期待値と実際の値が一致するか判定する関数です。
+//
+// 値はドライバが返す型(string, int64, float64, bool, time.Time, []byte など)で、
+// 期待値もカラムの型に従って同じ型に変換して渡します。
+// いずれかの値が NULL の場合は呼び出さず、両方が NULL の場合のみ一致とみなします。
+type ValueComparer func(want, got any) bool
+
+// EqualFold は文字列を大文字、小文字を区別せずに比較する ValueComparer です。
+// コード値など、大文字、小文字の違いを許容するカラムに利用します。
+func EqualFold() ValueComparer {
+	return func(want, got any) bool {
+		return strings.EqualFold(formatValue(want), formatValue(got))
+	}
+}
+
+// TruncateTime は日時を d の単位に切り捨てて比較する ValueComparer です。
+// 日時以外の値は切り捨てずに比較します。
+func TruncateTime(d time.Duration) ValueComparer {
+	return func(want, got any) bool {
+		w, ok1 := want.(time.Time)
+		g, ok2 := got.(time.Time)
+		if !ok1 || !ok2 {
+			return cmp.Equal(want, got)
+		}
+		return w.Truncate(d).Equal(g.Truncate(d))
+	}
+}
+
+// defaultCmpOptions は値の比較に既定で用いる go-cmp のオプションです。
+func defaultCmpOptions() []cmp.Option {
+	return []cmp.Option{
+		cmpopts.EquateNaNs(),
+		cmp.Comparer(func(x, y *big.Int) bool {
+			return x.Cmp(y) == 0
+		}),
+	}
+}
+
+// columnComparers はカラムごとの ValueComparer を返します。
+// CompareTableOption.Comparers、CompareRequest.TypeComparers の順に優先し、いずれも指定がないカラムは nil です。
+func columnComparers(tableName string, columns []string, types map[string]columnType, req *CompareRequest) []ValueComparer {
+	comparers := make([]ValueComparer, len(columns))
+	for i, c := range columns {
+		if f, ok := req.TableOptions[tableName].Comparers[c]; ok {
+			comparers[i] = f
+			continue
+		}
+		if ct, ok := types[c]; ok {
+			comparers[i] = req.TypeComparers[ct.name]
+		}
+	}
+	return comparers
+}
+
+// equal は i 番目のカラムの期待値と実際の値が一致するか判定します。
+// 期待値が matcher の場合は matcher で、ValueComparer が指定されている場合はその関数で、
+// それ以外は go-cmp で判定します。
+func (c *comparative) equal(i int, want, got any) bool {
+	if m, ok := want.(matcher); ok {
+		return m.match(got)
+	}
+	if i < len(c.comparers) && c.comparers[i] != nil {
+		if want == nil || got == nil {
+			return want == nil && got == nil
+		}
+		return c.comparers[i](want, got)
+	}
+	return cmp.Equal(want, got, c.opts...)
+}
+
+// equalRow は全カラムの値が一致するか判定します。
+func (c *comparative) equalRow(want, got []x) bool {
+	for i := range want {
+		if !c.equal(i, want[i].value, got[i].value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package exceltesting
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_comparative_equal(t *testing.T) {
+	m, err := parseMatcher(`<REGEX:^INV-\d+$>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2022, 1, 2, 10, 20, 30, 0, time.UTC)
+	c := &comparative{
+		comparers: []ValueComparer{nil, EqualFold(), TruncateTime(time.Second)},
+		opts:      append(defaultCmpOptions(), cmpopts.EquateApprox(0, 0.01)),
+	}
+	tests := []struct {
+		name      string
+		column    int
+		want, got any
+		equal     bool
+	}{
+		{name: "same value", column: 0, want: "a", got: "a", equal: true},
+		{name: "different value", column: 0, want: "a", got: "b", equal: false},
+		{name: "null", column: 0, want: nil, got: nil, equal: true},
+		{name: "nan", column: 0, want: math.NaN(), got: math.NaN(), equal: true},
+		{name: "cmp option", column: 0, want: 1.0, got: 1.005, equal: true},
+		{name: "matched", column: 0, want: m, got: "INV-0001", equal: true},
+		{name: "unmatched", column: 0, want: m, got: "0001", equal: false},
+		{name: "equal fold", column: 1, want: "abc", got: "ABC", equal: true},
+		{name: "comparer is not called with null", column: 1, want: "abc", got: nil, equal: false},
+		{name: "comparer with both null", column: 1, want: nil, got: nil, equal: true},
+		{name: "truncate time", column: 2, want: base, got: base.Add(999 * time.Millisecond), equal: true},
+		{name: "truncate time different second", column: 2, want: base, got: base.Add(time.Second), equal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.equal(tt.column, tt.want, tt.got); got != tt.equal {
+				t.Errorf("equal(%d, %v, %v) = %v, want %v", tt.column, tt.want, tt.got, got, tt.equal)
+			}
+		})
+	}
+}
+
+func Test_columnComparers(t *testing.T) {
+	fold := EqualFold()
+	truncate := TruncateTime(time.Second)
+	req := &CompareRequest{
+		TypeComparers: map[string]ValueComparer{"timestamptz": truncate, "varchar": truncate},
+		TableOptions: map[string]CompareTableOption{
+			"company": {Comparers: map[string]ValueComparer{"company_cd": fold}},
+		},
+	}
+	types := map[string]columnType{
+		"company_cd": {name: "varchar"},
+		"name":       {name: "text"},
+		"created_at": {name: "timestamptz"},
+	}
+	got := columnComparers("company", []string{"company_cd", "name", "created_at"}, types, req)
+	if len(got) != 3 || got[0] == nil || got[1] != nil || got[2] == nil {
+		t.Fatalf("columnComparers() = %v", got)
+	}
+	// カラムの比較関数を型の比較関数より優先する
+	if !got[0]("abc", "ABC") {
+		t.Errorf("column comparer should be used for company_cd")
+	}
+}
@@ -0,0 +1,227 @@
+package exceltesting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// DumpBookAsCSV はExcelブックの全シートをCSVにDumpします。
+// テストの外、たとえばCLIやCIからCSVファイルを出力、検証するために利用します。
+func DumpBookAsCSV(r DumpRequest) error {
+	return dumpBookAsCSV(r)
+}
+
+func dumpBookAsCSV(r DumpRequest) error {
+	var outdated []string
+
+	for _, path := range r.TargetBookPaths {
+		files, err := renderBookAsCSV(path)
+		if err != nil {
+			return err
+		}
+
+		outDir := r.OutputDir
+		if outDir == "" {
+			outDir = filepath.Join(filepath.Dir(path), "csv")
+		}
+
+		o, err := writeCSVFiles(outDir, path, true, files, r.Check)
+		if err != nil {
+			return err
+		}
+		outdated = append(outdated, o...)
+	}
+
+	if len(outdated) > 0 {
+		return fmt.Errorf("exceltesing: csv files are not up to date:\n%s", strings.Join(outdated, "\n"))
+	}
+	return nil
+}
+
+// dumpOpenedBookAsCSV は開いたExcelブックの全シートを、bookPath をブック名として outDir にCSVでDumpします。
+// fs.FS や io.Reader から開いたブックをDumpするために利用します。
+func dumpOpenedBookAsCSV(f *excelize.File, bookPath, outDir string) error {
+	files, err := renderSheetsAsCSV(f, bookPath)
+	if err != nil {
+		return err
+	}
+	_, err = writeCSVFiles(outDir, bookPath, false, files, false)
+	return err
+}
+
+// writeCSVFiles はCSVファイルを outDir に出力し、出力されないブックのCSVファイルを削除します。
+// check が有効な場合は出力せずに、最新でないCSVファイルを返します。
+// onDisk はブックがOSのファイルシステム上の bookPath から開いたものかどうかです。
+func writeCSVFiles(outDir, bookPath string, onDisk bool, files map[string][]byte, check bool) ([]string, error) {
+	stale, err := staleCSVFiles(outDir, bookPath, onDisk, files)
+	if err != nil {
+		return nil, err
+	}
+
+	if check {
+		var outdated []string
+		for _, name := range sortedKeys(files) {
+			b, err := os.ReadFile(filepath.Join(outDir, name))
+			if errors.Is(err, os.ErrNotExist) {
+				outdated = append(outdated, fmt.Sprintf("%s (missing)", filepath.Join(outDir, name)))
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("exceltesing: read file: %w", err)
+			}
+			if !bytes.Equal(b, files[name]) {
+				outdated = append(outdated, fmt.Sprintf("%s (modified)", filepath.Join(outDir, name)))
+			}
+		}
+		for _, name := range stale {
+			outdated = append(outdated, fmt.Sprintf("%s (deleted sheet)", filepath.Join(outDir, name)))
+		}
+		return outdated, nil
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("exceltesing: create directory: %w", err)
+	}
+	for _, name := range sortedKeys(files) {
+		if err := os.WriteFile(filepath.Join(outDir, name), files[name], 0644); err != nil {
+			return nil, fmt.Errorf("exceltesing: write file: %w", err)
+		}
+	}
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(outDir, name)); err != nil {
+			return nil, fmt.Errorf("exceltesing: remove file: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+// renderBookAsCSV はExcelブックの各シートをCSVに変換し、ファイル名とその内容を返します。
+// シートの解析は Load や Compare と同じく parseSheet を利用するため、シートのフォーマットバージョンに従います。
+// テーブル名が記載されていないシートと、データ行が存在しないシートは出力しません。
+func renderBookAsCSV(path string) (map[string][]byte, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
+	}
+	defer f.Close()
+
+	return renderSheetsAsCSV(f, path)
+}
+
+// renderSheetsAsCSV は開いたExcelブックの各シートをCSVに変換し、bookPath をブック名としたファイル名とその内容を返します。
+func renderSheetsAsCSV(f *excelize.File, path string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, sheetName := range f.GetSheetList() {
+		tableNm, err := f.GetCellValue(sheetName, "A2")
+		if err != nil {
+			return nil, fmt.Errorf("exceltesing: get cell value: %w", err)
+		}
+		if tableNm == "" {
+			continue
+		}
+
+		s, err := parseSheet(f, sheetName)
+		if err != nil {
+			return nil, fmt.Errorf("exceltesing: parse sheet, sheet = %s: %w", sheetName, err)
+		}
+		if len(s.table.data) == 0 {
+			continue
+		}
+
+		b, err := renderSheetAsCSV(s)
+		if err != nil {
+			return nil, fmt.Errorf("exceltesing: render csv, sheet = %s: %w", sheetName, err)
+		}
+		files[fmt.Sprintf("%s_%s.csv", getFileNameWithoutExt(path), sheetName)] = b
+	}
+	return files, nil
+}
+
+func renderSheetAsCSV(s *sheet) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	versionRow := []string{}
+	if s.version != "1.0" {
+		versionRow = []string{"version", s.version}
+	}
+
+	records := [][]string{
+		{s.logicalName},
+		{s.table.name},
+		versionRow,
+		s.logicalColumns,
+		s.table.columns,
+	}
+	records = append(records, s.table.data...)
+
+	if err := writer.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("writer.WriteAll(): %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// staleCSVFiles は outDir にある対象ブックのCSVファイルのうち、今回出力されないファイル名を返します。
+// シートを削除、リネームした場合や、シートのデータ行を削除した場合に残るCSVファイルが該当します。
+//
+// compare.xlsx と compare_v2.xlsx のようにブック名が前方一致する場合に、
+// 他のブックのCSVファイルを削除しないよう同じディレクトリのブック名も考慮します。
+// fs.FS などから開いたブック(onDisk が false)は、OSのファイルシステム上の無関係なディレクトリを参照しないよう考慮しません。
+func staleCSVFiles(outDir, bookPath string, onDisk bool, files map[string][]byte) ([]string, error) {
+	entries, err := os.ReadDir(outDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exceltesing: read directory: %w", err)
+	}
+
+	bookName := getFileNameWithoutExt(bookPath)
+	var otherBooks []string
+	if onDisk {
+		siblings, err := os.ReadDir(filepath.Dir(bookPath))
+		if err != nil {
+			return nil, fmt.Errorf("exceltesing: read directory: %w", err)
+		}
+		for _, sibling := range siblings {
+			name := getFileNameWithoutExt(sibling.Name())
+			if !sibling.IsDir() && filepath.Ext(sibling.Name()) == ".xlsx" && strings.HasPrefix(name, bookName+"_") {
+				otherBooks = append(otherBooks, name)
+			}
+		}
+	}
+
+	var stale []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, bookName+"_") || filepath.Ext(name) != ".csv" {
+			continue
+		}
+		if _, ok := files[name]; ok {
+			continue
+		}
+		if slices.IndexFunc(otherBooks, func(b string) bool { return strings.HasPrefix(name, b+"_") }) >= 0 {
+			continue
+		}
+		stale = append(stale, name)
+	}
+	return stale, nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
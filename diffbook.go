@@ -0,0 +1,144 @@
+package exceltesting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// diffBookAuthor は差分ブックに追加するコメントの作成者です
+	diffBookAuthor = "exceltesting"
+	// diffBookUnexpectedLabel は差分ブックに追記する想定外の行のA列の値です
+	diffBookUnexpectedLabel = "unexpected"
+)
+
+var (
+	// 値が異なるセルの背景色
+	diffBookChangedFill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#FFC7CE"}}
+	// 不足している行の背景色
+	diffBookMissingFill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#FFEB9C"}}
+	// 想定外の行の背景色
+	diffBookUnexpectedFill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#BDD7EE"}}
+)
+
+// writeDiffBook は期待値のExcelブックに比較結果の差分を書き込み、path に保存します。
+//
+// 値が異なるセルは背景色を付けて実際の値をコメントに記載し、不足している行は行全体に背景色を付けます。
+// 想定外の行はシートのデータ行の後ろに、A列を unexpected として追記します。
+// 差分のないシートはそのまま出力します。
+func writeDiffBook(f *excelize.File, path string, res *CompareResult) error {
+	styles := make(map[string]int, 3)
+	for name, fill := range map[string]excelize.Fill{
+		"changed":    diffBookChangedFill,
+		"missing":    diffBookMissingFill,
+		"unexpected": diffBookUnexpectedFill,
+	} {
+		id, err := f.NewStyle(&excelize.Style{Fill: fill})
+		if err != nil {
+			return fmt.Errorf("exceltesting: create style: %w", err)
+		}
+		styles[name] = id
+	}
+
+	for _, t := range res.Tables {
+		if t.Equal() {
+			continue
+		}
+		if t.Err != nil {
+			if err := addDiffComment(f, t.Sheet, "A1", t.Err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := parseSheet(f, t.Sheet)
+		if err != nil {
+			return fmt.Errorf("exceltesting: parse sheet, sheet = %s: %w", t.Sheet, err)
+		}
+		lastCol, err := excelize.ColumnNumberToName(len(s.table.columns) + 1)
+		if err != nil {
+			return fmt.Errorf("exceltesting: column name: %w", err)
+		}
+
+		for _, c := range t.Changed {
+			if c.Cell == "" {
+				continue
+			}
+			cell := cellOf(c.Cell)
+			if err := f.SetCellStyle(t.Sheet, cell, cell, styles["changed"]); err != nil {
+				return fmt.Errorf("exceltesting: set cell style: %w", err)
+			}
+			if err := addDiffComment(f, t.Sheet, cell, fmt.Sprintf("actual: %s", describeValue(c.Got))); err != nil {
+				return err
+			}
+		}
+
+		for _, r := range t.Missing {
+			if r.Cell == "" {
+				continue
+			}
+			cell := cellOf(r.Cell)
+			_, row, err := excelize.CellNameToCoordinates(cell)
+			if err != nil {
+				return fmt.Errorf("exceltesting: cell name: %w", err)
+			}
+			if err := f.SetCellStyle(t.Sheet, cell, fmt.Sprintf("%s%d", lastCol, row), styles["missing"]); err != nil {
+				return fmt.Errorf("exceltesting: set cell style: %w", err)
+			}
+			if err := addDiffComment(f, t.Sheet, cell, "missing: the row does not exist in the table"); err != nil {
+				return err
+			}
+		}
+
+		rows, err := f.GetRows(t.Sheet)
+		if err != nil {
+			return fmt.Errorf("exceltesting: get rows: %w", err)
+		}
+		for i, r := range t.Unexpected {
+			row := len(rows) + i + 1
+			values := make([]any, len(s.table.columns)+1)
+			values[0] = diffBookUnexpectedLabel
+			for j, v := range r.Values {
+				if idx := slices.Index(s.table.columns, t.Columns[j]); idx >= 0 {
+					values[idx+1] = sheetValue(v, t.columnType(j))
+				}
+			}
+			if err := f.SetSheetRow(t.Sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+				return fmt.Errorf("exceltesting: set sheet row: %w", err)
+			}
+			if err := f.SetCellStyle(t.Sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("%s%d", lastCol, row), styles["unexpected"]); err != nil {
+				return fmt.Errorf("exceltesting: set cell style: %w", err)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("exceltesting: create directory: %w", err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("exceltesting: save diff book: %w", err)
+	}
+	return nil
+}
+
+func addDiffComment(f *excelize.File, sheet, cell, text string) error {
+	format, err := json.Marshal(excelize.Comment{Author: diffBookAuthor + ": ", Text: text})
+	if err != nil {
+		return fmt.Errorf("exceltesting: marshal comment: %w", err)
+	}
+	if err := f.AddComment(sheet, cell, string(format)); err != nil {
+		return fmt.Errorf("exceltesting: add comment: %w", err)
+	}
+	return nil
+}
+
+// cellOf は 会社!D7 のようなシート名を含むセルの位置から、セルの位置 D7 を返します。
+func cellOf(cell string) string {
+	return cell[strings.LastIndex(cell, "!")+1:]
+}
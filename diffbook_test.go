@@ -0,0 +1,71 @@
+package exceltesting
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_writeDiffBook(t *testing.T) {
+	f, err := excelize.OpenFile(filepath.Join("testdata", "compare_matcher.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	out := filepath.Join(t.TempDir(), "diff", "compare_matcher_diff.xlsx")
+	res := &CompareResult{Tables: []TableResult{{
+		Sheet:   "会社",
+		Table:   "company",
+		Columns: []string{"company_cd", "company_name", "revision"},
+		Missing: []RowDiff{{Key: "company_cd=00001", Cell: "会社!A7", Values: []any{"00001", "Future", int64(1)}}},
+		Unexpected: []RowDiff{
+			{Key: "company_cd=00009", Values: []any{"00009", "Unknown", int64(1)}},
+		},
+		Changed: []CellDiff{
+			{Key: "company_cd=00002", Column: "revision", Want: "1", Got: int64(2), Cell: "会社!G8"},
+		},
+	}}}
+	if err := writeDiffBook(f, out, res); err != nil {
+		t.Fatalf("writeDiffBook() error: %v", err)
+	}
+
+	got, err := excelize.OpenFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+
+	var comments []string
+	for _, c := range got.GetComments()["会社"] {
+		comments = append(comments, c.Ref+": "+c.Text)
+	}
+	wantComments := []string{
+		"G8: exceltesting: actual: 2",
+		"A7: exceltesting: missing: the row does not exist in the table",
+	}
+	if diff := cmp.Diff(wantComments, comments); diff != "" {
+		t.Errorf("comments mismatch (-want +got):\n%s", diff)
+	}
+
+	rows, err := got.GetRows("会社")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRow := []string{"unexpected", "00009", "Unknown", "", "", "", "1"}
+	if diff := cmp.Diff(wantRow, rows[len(rows)-1]); diff != "" {
+		t.Errorf("unexpected row mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, cell := range []string{"A7", "G7", "G8", "A9"} {
+		style, err := got.GetCellStyle("会社", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style == 0 {
+			t.Errorf("cell %s should be highlighted", cell)
+		}
+	}
+}
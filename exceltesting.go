@@ -3,262 +3,458 @@ package exceltesting
 import (
 	"context"
 	"database/sql"
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"math/big"
-	"os"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"strings"
-	"testing"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/xuri/excelize/v2"
 	"golang.org/x/exp/slices"
 )
 
-const (
-	tempTablePrefix = "temp_"
+// DB は exceltesting がデータベースにアクセスするためのインターフェースです。
+// *sql.DB と *sql.Conn が満たします。search_path などセッションの設定を利用する場合は *sql.Conn を指定します。
+type DB interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var (
+	_ DB = (*sql.DB)(nil)
+	_ DB = (*sql.Conn)(nil)
 )
 
 // New はExcelからテストデータを投入できる構造体のファクトリ関数です
-func New(db *sql.DB) *exceltesing {
-	if db == nil {
+func New(db DB) *exceltesing {
+	if db == nil || db == (*sql.DB)(nil) || db == (*sql.Conn)(nil) {
 		panic("db is nil")
 	}
-	return &exceltesing{db}
+	return &exceltesing{db: db}
 }
 
 type exceltesing struct {
-	db *sql.DB
+	db DB
+	// ledger は EnableSkipUnchanged で用いる投入の記録です
+	ledger loadLedger
 }
 
 // Load はExcelのBookを読み込み、データベースに事前データを投入します。
-func (e *exceltesing) Load(t *testing.T, r LoadRequest) {
+// シートはサブテストにせずに投入し、失敗した場合はシート名を付与して報告し、以降のシートを投入せずにテストを終了します。
+func (e *exceltesing) Load(t Reporter, r LoadRequest) {
 	t.Helper()
 	ctx := context.Background()
 
-	if err := e.LoadWithContext(ctx, r); err != nil {
+	if err := r.CellNormalization.validate(); err != nil {
+		t.Fatalf("load: exceltesing: %v", err)
+	}
+	if err := r.Lock.validate(); err != nil {
+		t.Fatalf("load: exceltesing: %v", err)
+	}
+
+	wb, err := openWorkbook(r.bookSource())
+	if err != nil {
+		t.Fatalf("load: exceltesing: %v", err)
+	}
+	defer wb.Close()
+
+	sheets := targetSheets(wb.sheetList(), r.SheetPrefix, r.IgnoreSheet)
+	if err := e.lockForTest(t, lockKeys(r.Lock, wb, sheets)); err != nil {
+		t.Fatalf("load: exceltesing: %v", err)
+	}
+	for _, sheet := range sheets {
+		// サブテストは go test -run で絞り込まれると実行されないため、すべてのシートをそのまま投入する
+		sr := &sheetReporter{Reporter: t, sheet: sheet}
+		if err := e.loadSheet(ctx, wb, sheet, &r); err != nil {
+			sr.Fatalf("load: %v", err)
+			return
+		}
+	}
+
+	if err := e.finishLoad(wb, &r); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 }
 
 func (e *exceltesing) LoadWithContext(ctx context.Context, r LoadRequest) error {
+	if err := r.CellNormalization.validate(); err != nil {
+		return fmt.Errorf("exceltesing: %w", err)
+	}
+	if err := r.Lock.validate(); err != nil {
+		return fmt.Errorf("exceltesing: %w", err)
+	}
+
 	tx, err := e.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("exceltesing: start transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	f, err := excelize.OpenFile(r.TargetBookPath)
+	wb, err := openWorkbook(r.bookSource())
 	if err != nil {
-		return fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
+		return fmt.Errorf("exceltesing: %w", err)
 	}
-	defer f.Close()
+	defer wb.Close()
 
-	for _, sheet := range f.GetSheetList() {
-		if slices.Contains(r.IgnoreSheet, sheet) {
+	sheets := targetSheets(wb.sheetList(), r.SheetPrefix, r.IgnoreSheet)
+	unlock, err := e.lockForCall(ctx, lockKeys(r.Lock, wb, sheets))
+	if err != nil {
+		return fmt.Errorf("exceltesing: %w", err)
+	}
+	defer unlock()
+	for _, sheet := range sheets {
+		if err := e.loadSheet(ctx, wb, sheet, &r); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("exceltesing: commit: %w", err)
+	}
+
+	return e.finishLoad(wb, &r)
+}
+
+// targetSheets はブックのシートのうち、prefix で始まり ignore に含まれないシートを返します。
+func targetSheets(sheetList []string, prefix string, ignore []string) []string {
+	var sheets []string
+	for _, sheet := range sheetList {
+		if slices.Contains(ignore, sheet) {
 			continue
 		}
-		if strings.HasPrefix(sheet, r.SheetPrefix) {
-			table, err := e.loadExcelSheet(f, sheet)
-			if err != nil {
-				return fmt.Errorf("exceltesing: load excel sheet, sheet = %s: %w", sheet, err)
-			}
+		if strings.HasPrefix(sheet, prefix) {
+			sheets = append(sheets, sheet)
+		}
+	}
+	return sheets
+}
 
-			if r.EnableAutoCompleteNotNullColumn {
-				cs, err := e.tableColumns(table.name)
-				if err != nil {
-					return fmt.Errorf("exceltesing: get table(%s)'s columns: %w", table.name, err)
-				}
-				for i := range cs {
-					cs[i].data = defaultValueFromDBType(cs[i].dataType)
-				}
-				table.merge(cs)
-			}
+// loadSheet はシートのデータをテーブルに投入します。
+func (e *exceltesing) loadSheet(ctx context.Context, wb *workbook, sheet string, r *LoadRequest) error {
+	table, err := e.loadExcelSheet(wb, sheet)
+	if err != nil {
+		return fmt.Errorf("exceltesing: load excel sheet, sheet = %s: %w", sheet, err)
+	}
+	normalizeCells(table, r.CellNormalization)
 
-			if err := e.insertData(table); err != nil {
-				return fmt.Errorf("exceltesing: insert data to %s: %w", table.name, err)
-			}
+	if r.EnableAutoCompleteNotNullColumn {
+		cs, err := e.tableColumns(table.name)
+		if err != nil {
+			return fmt.Errorf("exceltesing: get table(%s)'s columns: %w", table.name, err)
+		}
+		for i := range cs {
+			cs[i].data = defaultValueFromDBType(cs[i].dataType)
 		}
+		table.merge(cs)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("exceltesing: commit: %w", err)
+	if !r.EnableSkipUnchanged {
+		if err := e.insertData(table); err != nil {
+			return fmt.Errorf("exceltesing: insert data to %s: %w", table.name, err)
+		}
+		return nil
+	}
+
+	checksum := table.checksum()
+	unchanged, err := e.unchangedSinceLoad(ctx, table.name, checksum)
+	if err != nil {
+		return fmt.Errorf("exceltesing: check load ledger of %s: %w", table.name, err)
+	}
+	if unchanged {
+		return nil
+	}
+	if err := e.forgetLoad(ctx, table.name); err != nil {
+		return fmt.Errorf("exceltesing: %w", err)
+	}
+	if err := e.insertData(table); err != nil {
+		return fmt.Errorf("exceltesing: insert data to %s: %w", table.name, err)
 	}
+	if err := e.recordLoad(ctx, table.name, checksum); err != nil {
+		return fmt.Errorf("exceltesing: record load ledger of %s: %w", table.name, err)
+	}
+	return nil
+}
 
+// finishLoad は全てのシートを投入した後の処理を行います。
+func (e *exceltesing) finishLoad(wb *workbook, r *LoadRequest) error {
 	if r.EnableDumpCSV {
-		if err := e.dumpBookAsCSV(r.TargetBookPath); err != nil {
+		if err := wb.dumpCSV(r.DumpCSVDir); err != nil {
 			return fmt.Errorf("dump csv: %w", err)
 		}
 	}
-
 	return nil
 }
 
 // Compare はExcelの期待結果と実際にデータベースに登録されているデータを比較して
 // 差分がある場合は報告します。
+// t が *testing.T の場合はシートごとに t.Run(シート名, ...) のサブテストとして比較するため、
+// go test -run 'TestX/company' のように特定のシートのみ比較できます。差分があっても以降のシートの比較を続けます。
 // 値の比較は go-cmp (https://github.com/google/go-cmp) を利用しています。
-func (e *exceltesing) Compare(t *testing.T, r CompareRequest) bool {
+func (e *exceltesing) Compare(t Reporter, r CompareRequest) bool {
 	t.Helper()
 
-	equal, errors := e.CompareWithContext(context.Background(), r)
-	for _, err := range errors {
-		t.Error(err)
+	if err := r.CellNormalization.validate(); err != nil {
+		t.Errorf("exceltesting: %v", err)
+		return false
+	}
+	if err := r.Lock.validate(); err != nil {
+		t.Errorf("exceltesting: %v", err)
+		return false
+	}
+
+	wb, err := openWorkbook(r.bookSource())
+	if err != nil {
+		t.Errorf("exceltesting: failed to open excel file: %v", err)
+		return false
+	}
+	defer wb.Close()
+
+	sheets := targetSheets(wb.sheetList(), r.SheetPrefix, r.IgnoreSheet)
+	if err := e.lockForTest(t, lockKeys(r.Lock, wb, sheets)); err != nil {
+		t.Errorf("exceltesting: %v", err)
+		return false
+	}
+	res := &CompareResult{}
+	for _, sheet := range sheets {
+		runSheet(t, sheet, func(t Reporter) {
+			t.Helper()
+			table := e.compareSheet(wb, sheet, &r)
+			res.Tables = append(res.Tables, table)
+			// 期待値を更新する場合は、更新できなかったシートのみ失敗とする
+			if !table.Equal() && !(updateEnabled(&r) && table.updatable()) {
+				t.Errorf("%s", table)
+			}
+		})
+	}
+
+	if err := e.finishCompare(wb, &r, res); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	for _, table := range res.Tables {
+		if table.Updated {
+			logf(t, "exceltesting: %s", table)
+		}
 	}
 
-	return equal
+	return res.Equal()
 }
 
-func (e *exceltesing) CompareWithContext(_ context.Context, r CompareRequest) (bool, []error) {
-	tx, err := e.db.Begin()
+// CompareWithContext はExcelの期待結果と実際にデータベースに登録されているデータを比較します。
+// 差分がある場合はテーブルごとの差分をエラーとして返します。
+func (e *exceltesing) CompareWithContext(ctx context.Context, r CompareRequest) (bool, []error) {
+	res, err := e.CompareWithResult(ctx, r)
 	if err != nil {
-		return false, []error{fmt.Errorf("exceltesting: failed to start transaction: %w", err)}
+		return false, []error{err}
 	}
-	defer tx.Rollback()
+	return res.Equal(), res.Errors()
+}
+
+// CompareWithResult はExcelの期待結果と実際にデータベースに登録されているデータを比較して、
+// テーブルごとの比較結果を返します。
+// 差分は不足している行、想定外の行、値が異なるセルとして構造化されているため、
+// 独自のレポートを作成する場合などに利用します。
+func (e *exceltesing) CompareWithResult(ctx context.Context, r CompareRequest) (*CompareResult, error) {
+	if err := r.CellNormalization.validate(); err != nil {
+		return nil, fmt.Errorf("exceltesting: %w", err)
+	}
+	if err := r.Lock.validate(); err != nil {
+		return nil, fmt.Errorf("exceltesting: %w", err)
+	}
+
+	wb, err := openWorkbook(r.bookSource())
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: failed to open excel file: %w", err)
+	}
+	defer wb.Close()
 
-	f, err := excelize.OpenFile(r.TargetBookPath)
+	sheets := targetSheets(wb.sheetList(), r.SheetPrefix, r.IgnoreSheet)
+	unlock, err := e.lockForCall(ctx, lockKeys(r.Lock, wb, sheets))
 	if err != nil {
-		return false, []error{fmt.Errorf("exceltesting: failed to open excel file: %w", err)}
+		return nil, fmt.Errorf("exceltesting: %w", err)
+	}
+	defer unlock()
+	res := &CompareResult{}
+	for _, sheet := range sheets {
+		res.Tables = append(res.Tables, e.compareSheet(wb, sheet, &r))
 	}
-	defer f.Close()
 
-	equal := true
-	var errs []error
+	if err := e.finishCompare(wb, &r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
-	for _, sheet := range f.GetSheetList() {
-		if slices.Contains(r.IgnoreSheet, sheet) {
-			continue
+// finishCompare は全てのシートを比較した後に、期待値の更新や差分の報告を行います。
+func (e *exceltesing) finishCompare(wb *workbook, r *CompareRequest, res *CompareResult) error {
+	if r.DiffBookPath != "" && !res.Equal() {
+		// 差分ブックには比較した期待値を残すよう、期待値を更新する前にブックを開く
+		if _, err := wb.file(); err != nil {
+			return fmt.Errorf("exceltesting: failed to open excel file: %w", err)
 		}
-		if strings.HasPrefix(sheet, r.SheetPrefix) {
-			table, err := e.loadExcelSheet(f, sheet)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("exceltesting: failed to load excel sheet, sheet = %s: %v", sheet, err))
-				equal = false
-				continue
-			}
-			got, want, err := e.comparativeSource(table, &r)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("exceltesting: failed to fetch comparative source: %w", err))
-				equal = false
-				continue
-			}
+	}
 
-			opts := []cmp.Option{
-				cmpopts.EquateNaNs(),
-				cmp.Comparer(func(x, y *big.Int) bool {
-					return x.Cmp(y) == 0
-				}),
-				cmp.AllowUnexported(x{}),
-			}
-			if diff := cmp.Diff(want, got, opts...); diff != "" {
-				errs = append(errs, fmt.Errorf("table(%s) mismatch (-want +got):\n%s", table.name, diff))
-				equal = false
-				continue
-			}
+	if updateEnabled(r) && !res.Equal() {
+		if !r.bookSource().onDisk() {
+			return errors.New("exceltesting: update requires a book opened from TargetBookPath on the file system")
+		}
+		// 差分ブックの書き込みが期待値のブックに含まれないよう、ブックを開き直して更新する
+		if err := updateBook(r.TargetBookPath, res); err != nil {
+			return err
 		}
 	}
 
-	if r.EnableDumpCSV {
-		if err := e.dumpBookAsCSV(r.TargetBookPath); err != nil {
-			return false, []error{fmt.Errorf("dump csv: %w", err)}
+	if r.HTMLReportPath != "" {
+		title := "exceltesting"
+		if r.TargetBookPath != "" {
+			title = filepath.Base(r.TargetBookPath)
+		}
+		if err := writeHTMLReport(r.HTMLReportPath, title, res); err != nil {
+			return err
 		}
 	}
 
-	return equal, errs
-}
+	if r.DiffBookPath != "" && !res.Equal() {
+		f, err := wb.file()
+		if err != nil {
+			return fmt.Errorf("exceltesting: failed to open excel file: %w", err)
+		}
+		if err := writeDiffBook(f, r.DiffBookPath, res); err != nil {
+			return err
+		}
+	}
 
-// DumpCSV はExcelブックの全シートをCSVにDumpします。
-//
-// DumpRequest.TargetBookPaths で指定されたパスにディレクトリを作成し、
-// CSVファイルをDumpします。
-//
-// Deprecated: LoadRequest.EnableDumpCSV や CompareRequest.EnableDumpCSV のオプションを利用してください
-func (e *exceltesing) DumpCSV(t *testing.T, r DumpRequest) {
-	t.Helper()
+	if r.EnableDumpCSV {
+		if err := wb.dumpCSV(r.DumpCSVDir); err != nil {
+			return fmt.Errorf("dump csv: %w", err)
+		}
+	}
 
-	e.dumpCSV(t, r.TargetBookPaths...)
+	return nil
 }
 
-func (e *exceltesing) dumpCSV(t *testing.T, paths ...string) {
-	t.Helper()
+// compareSheet はシートの期待値と実際のテーブルの値を比較します。
+func (e *exceltesing) compareSheet(wb *workbook, sheetName string, r *CompareRequest) TableResult {
+	res := TableResult{Sheet: sheetName}
 
-	if err := e.dumpBookAsCSV(paths...); err != nil {
-		t.Error(err)
+	s, err := wb.sheet(sheetName)
+	if err != nil {
+		res.Err = fmt.Errorf("exceltesting: failed to load excel sheet, sheet = %s: %v", sheetName, err)
+		return res
 	}
-}
+	res.Table = s.table.name
 
-func (e *exceltesing) dumpBookAsCSV(paths ...string) error {
-	const columnsRowNum = 9
-
-	for _, path := range paths {
-		ef, err := excelize.OpenFile(path)
-		if err != nil {
-			return fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
+	mode, err := compareMode(s, r)
+	if err != nil {
+		res.Err = fmt.Errorf("exceltesting: sheet = %s: %w", sheetName, err)
+		return res
+	}
+	res.mode = mode
+	c, err := e.comparativeSource(s, r)
+	if err != nil {
+		res.Err = fmt.Errorf("exceltesting: failed to fetch comparative source: %w", err)
+		return res
+	}
+	res.Columns = c.columns
+	res.types = c.types
+	res.LogicalColumns = make([]string, len(c.columns))
+	for i, column := range c.columns {
+		if idx := slices.Index(s.table.columns, column); idx >= 0 && idx < len(s.logicalColumns) {
+			res.LogicalColumns[i] = s.logicalColumns[idx]
 		}
-		defer ef.Close()
+	}
+	if mode != CompareModeExact {
+		if err := validateKeyColumns(s.table, c.key, r); err != nil {
+			res.Err = fmt.Errorf("exceltesting: table(%s) compare mode %s: %w", s.table.name, mode, err)
+			return res
+		}
+	}
 
-		for _, sheet := range ef.GetSheetList() {
-			rows, err := ef.Rows(sheet)
-			if err != nil {
-				return fmt.Errorf("exceltesing: rows: %w", err)
-			}
-			rr, err := ef.GetRows(sheet)
-			if err != nil {
-				return fmt.Errorf("exceltesing: get rows: %w", err)
-			}
-			outDir := filepath.Join(filepath.Dir(path), "csv")
-			if _, err := os.Stat(outDir); os.IsNotExist(err) {
-				if err := os.Mkdir(outDir, 0755); err != nil {
-					return fmt.Errorf("exceltesing: create directory: %w", err)
-				}
-			}
+	switch mode {
+	case CompareModeContains:
+		// 期待値に記載されていない行は比較しない
+		c.got = filterRowsByKey(c.got, c.want, c.key)
+	case CompareModeAbsent:
+		for _, row := range filterRowsByKey(c.got, c.want, c.key) {
+			res.Unexpected = append(res.Unexpected, c.rowDiff(row, c.describeRow(row, 0), ""))
+			res.rows = append(res.rows, comparedRow{key: c.describeRow(row, 0), got: values(row)})
+		}
+		return res
+	}
 
-			if len(rr) == columnsRowNum {
+	matched := c.matchRows()
+	used := make([]bool, len(c.got))
+	for i, want := range c.want {
+		key := c.describeRow(want, c.wantRowNums[i])
+		if matched[i] < 0 {
+			res.Missing = append(res.Missing, c.rowDiff(want, key, cellName(sheetName, 1, c.wantRowNums[i])))
+			res.rows = append(res.rows, comparedRow{key: key, want: values(want)})
+			continue
+		}
+		used[matched[i]] = true
+		got := c.got[matched[i]]
+		row := comparedRow{key: key, want: values(want), got: values(got), changed: make([]bool, len(c.columns))}
+		for j, column := range c.columns {
+			if c.equal(j, want[j].value, got[j].value) {
 				continue
 			}
+			row.changed[j] = true
+			res.Changed = append(res.Changed, CellDiff{
+				Key:    key,
+				Column: column,
+				Want:   want[j].value,
+				Got:    got[j].value,
+				Cell:   cellName(sheetName, slices.Index(s.table.columns, column)+2, c.wantRowNums[i]),
+			})
+		}
+		res.rows = append(res.rows, row)
+	}
+	for i, got := range c.got {
+		if !used[i] {
+			res.Unexpected = append(res.Unexpected, c.rowDiff(got, c.describeRow(got, 0), ""))
+			res.rows = append(res.rows, comparedRow{key: c.describeRow(got, 0), got: values(got)})
+		}
+	}
 
-			outFileName := fmt.Sprintf("%s_%s.csv", getFileNameWithoutExt(path), sheet)
-			f, err := os.Create(filepath.Join(outDir, outFileName))
-			if err != nil {
-				return fmt.Errorf("exceltesing: create file: %w", err)
-			}
-			defer f.Close()
+	return res
+}
 
-			writer := csv.NewWriter(f)
-			defer writer.Flush()
+// DumpCSV はExcelブックの全シートをCSVにDumpします。
+//
+// DumpRequest.TargetBookPaths で指定されたパスにディレクトリを作成し、
+// CSVファイルをDumpします。
+// DumpRequest.Check が有効な場合はDumpせずに、出力済みのCSVファイルがExcelブックと一致しているか検証します。
+//
+// Deprecated: LoadRequest.EnableDumpCSV や CompareRequest.EnableDumpCSV のオプションを利用してください
+func (e *exceltesing) DumpCSV(t Reporter, r DumpRequest) {
+	t.Helper()
 
-			rowCnt := 0
-			for rows.Next() {
-				cols, err := rows.Columns()
-				if err != nil {
-					return fmt.Errorf("exceltesing: rows.Columns: %w", err)
-				}
-				if 3 <= rowCnt && rowCnt <= 6 {
-					rowCnt++
-					continue
-				}
-				if rowCnt >= 7 {
-					if len(cols) == 0 {
-						rowCnt++
-						continue
-					}
-					cols = cols[1:]
-				}
-				if err := writer.Write(cols); err != nil {
-					return fmt.Errorf("exceltesing: writer.Write(): %w", err)
-				}
-				rowCnt++
-			}
-		}
+	e.dumpCSV(t, r)
+}
+
+func (e *exceltesing) dumpCSV(t Reporter, r DumpRequest) {
+	t.Helper()
+
+	if err := dumpBookAsCSV(r); err != nil {
+		t.Errorf("%v", err)
 	}
-	return nil
 }
 
 // LoadRequest はExcelからデータを投入するための設定です。
 type LoadRequest struct {
 	// ロード対象Excelパス
+	// TargetBookFS を指定した場合は fs.FS 上のパス、TargetBookReader、TargetBook を指定した場合はCSVファイルのブック名として用います
 	TargetBookPath string
+	// TargetBookFS を指定した場合は TargetBookPath を fs.FS (embed.FS など)から開きます
+	TargetBookFS fs.FS
+	// TargetBookReader を指定した場合は TargetBookPath の代わりに読み込みます
+	TargetBookReader io.Reader
+	// TargetBook を指定した場合は開いたブックをそのまま利用します。ブックは閉じません
+	TargetBook *excelize.File
 	// ロード対象シートプレフィックス
 	SheetPrefix string
 	// 無視シート
@@ -268,30 +464,175 @@ type LoadRequest struct {
 	EnableAutoCompleteNotNullColumn bool
 	// EnableDumpCSV はExcelファイルをCSVファイルとしてDumpします
 	EnableDumpCSV bool
+	// DumpCSVDir はCSVファイルの出力先ディレクトリです
+	// 未指定の場合はExcelファイルと同じディレクトリの csv ディレクトリに出力します
+	// TargetBookFS、TargetBookReader、TargetBook を指定した場合は必須です
+	DumpCSVDir string
+	// CellNormalization はデータ行のセルの値の正規化方法です。未指定の場合は CellNormalizationPreserve です
+	CellNormalization CellNormalization
+	// EnableSkipUnchanged は前回の投入時とシートのデータが同じで、投入後にテーブルが変更されていない場合に、
+	// テーブルの TRUNCATE と INSERT を省略します
+	// 投入したデータのチェックサムと、トリガーで数えた投入後のテーブルの変更回数を exceltesting_load_ledger テーブルに記録して判定します
+	EnableSkipUnchanged bool
+	// Lock は投入時に取得するアドバイザリロックの範囲です。未指定の場合はロックを取得しません
+	// Load はテストの終了まで、LoadWithContext は呼び出しの間のみロックを保持します
+	Lock LockScope
+}
+
+func (r *LoadRequest) bookSource() bookSource {
+	return bookSource{path: r.TargetBookPath, fsys: r.TargetBookFS, reader: r.TargetBookReader, book: r.TargetBook}
 }
 
 // CompareRequest はExcelとデータベースの値を比較するための設定です。
 type CompareRequest struct {
 	// ロード対象Excelパス
+	// TargetBookFS を指定した場合は fs.FS 上のパス、TargetBookReader、TargetBook を指定した場合はCSVファイルのブック名として用います
 	TargetBookPath string
+	// TargetBookFS を指定した場合は TargetBookPath を fs.FS (embed.FS など)から開きます
+	TargetBookFS fs.FS
+	// TargetBookReader を指定した場合は TargetBookPath の代わりに読み込みます
+	TargetBookReader io.Reader
+	// TargetBook を指定した場合は開いたブックをそのまま利用します。ブックは閉じません
+	TargetBook *excelize.File
 	// ロード対象シートプレフィックス
 	SheetPrefix string
 	// 無視シート
 	IgnoreSheet []string
 	// 無視するカラム名
 	IgnoreColumns []string
+	// CellNormalization は期待値のセルの値の正規化方法です。未指定の場合は CellNormalizationPreserve です
+	// 投入時と同じ方法を指定します
+	CellNormalization CellNormalization
+	// Mode は比較方法です。未指定の場合は CompareModeExact です
+	Mode CompareMode
+	// CmpOptions は値の比較に用いる go-cmp のオプションです。NaN を等しいとみなすなどの既定のオプションに追加します
+	CmpOptions []cmp.Option
+	// TypeComparers はデータベースの型ごとの比較関数です。キーは pg_type の型名(timestamptz, bpchar など)です
+	// ドメインのカラムは基底型の型名を用います
+	TypeComparers map[string]ValueComparer
+	// TableOptions はテーブル単位の比較設定です。キーはテーブル名です
+	// シートの3行目に同じ設定が記載されている場合はシートの設定を優先します
+	TableOptions map[string]CompareTableOption
 	// EnableDumpCSV はExcelファイルをCSVファイルとしてDumpします
 	EnableDumpCSV bool
+	// DumpCSVDir はCSVファイルの出力先ディレクトリです
+	// 未指定の場合はExcelファイルと同じディレクトリの csv ディレクトリに出力します
+	// TargetBookFS、TargetBookReader、TargetBook を指定した場合は必須です
+	DumpCSVDir string
+	// DiffBookPath は比較結果が一致しない場合に、期待値のExcelファイルに差分を書き込んだファイルの出力先パスです
+	// 未指定の場合は出力しません
+	DiffBookPath string
+	// HTMLReportPath は全シートの比較結果を記載したHTMLレポートの出力先パスです
+	// 比較結果が一致した場合も出力します。未指定の場合は出力しません
+	HTMLReportPath string
+	// Update は比較結果が一致しない場合に、期待値のシートのデータ行を実際のテーブルの値で書き換えます
+	// go test の -exceltesting.update フラグで切り替える場合は UpdateFlag を利用します
+	// 書き換えたシートは一致したものとみなします
+	// TargetBookPath のファイルを書き換えるため、TargetBookFS、TargetBookReader、TargetBook を指定した場合は利用できません
+	Update bool
+	// Lock は比較時に取得するアドバイザリロックの範囲です。未指定の場合はロックを取得しません
+	// Compare はテストの終了まで、CompareWithContext、CompareWithResult は呼び出しの間のみロックを保持します
+	Lock LockScope
+}
+
+func (r *CompareRequest) bookSource() bookSource {
+	return bookSource{path: r.TargetBookPath, fsys: r.TargetBookFS, reader: r.TargetBookReader, book: r.TargetBook}
+}
+
+// CompareTableOption はテーブル単位の比較設定です。
+type CompareTableOption struct {
+	// OrderBy は実際の値と期待値を突き合わせる際の並び順に用いるカラム名です
+	// 未指定の場合は主キー、主キーが存在しないテーブルやビューの場合は比較対象の全カラムを用います
+	// シートでは order_by に カンマ区切りで記載します
+	OrderBy []string
+	// Mode は比較方法です。未指定の場合は CompareRequest.Mode に従います
+	// シートでは mode に記載します
+	Mode CompareMode
+	// Where は実際の値を取得する際の絞り込み条件で、WHERE 句に指定します
+	// 期待値には条件に一致する行のみ記載します
+	// シートでは where に記載します
+	Where string
+	// Comparers はカラム名ごとの比較関数です。CompareRequest.TypeComparers より優先します
+	Comparers map[string]ValueComparer
+}
+
+// CompareMode は期待値と実際のテーブルの行を比較する方法です。
+// CompareModeContains と CompareModeAbsent は主キー(もしくは order_by)で行を突き合わせます。
+type CompareMode string
+
+const (
+	// CompareModeExact はテーブルの行が期待値の行と過不足なく一致することを検証します
+	CompareModeExact CompareMode = "exact"
+	// CompareModeContains は期待値の行がテーブルに存在することを検証します。期待値にない行がテーブルに存在しても許容します
+	CompareModeContains CompareMode = "contains"
+	// CompareModeAbsent は期待値に記載したキーの行がテーブルに存在しないことを検証します
+	CompareModeAbsent CompareMode = "absent"
+)
+
+// compareMode はシートの mode、CompareRequest.TableOptions の Mode、CompareRequest.Mode の順に優先して比較方法を返します。
+func compareMode(s *sheet, req *CompareRequest) (CompareMode, error) {
+	mode := CompareModeExact
+	if req.Mode != "" {
+		mode = req.Mode
+	}
+	if o, ok := req.TableOptions[s.table.name]; ok && o.Mode != "" {
+		mode = o.Mode
+	}
+	if v := s.options[sheetOptionMode]; v != "" {
+		mode = CompareMode(strings.ToLower(v))
+	}
+
+	switch mode {
+	case CompareModeExact, CompareModeContains, CompareModeAbsent:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown compare mode: %s", mode)
 }
 
 // DumpRequest はExcelをCSVにDumpするための設定です。
 type DumpRequest struct {
 	// dump対象Excelパス
 	TargetBookPaths []string
+	// OutputDir はCSVファイルの出力先ディレクトリです
+	// 未指定の場合はExcelファイルと同じディレクトリの csv ディレクトリに出力します
+	OutputDir string
+	// Check はCSVファイルを出力せずに、出力済みのCSVファイルがExcelファイルの内容と一致しているか検証します
+	// CIで CSV ファイルの更新漏れを検知するために利用します
+	Check bool
 }
 
-func (e *exceltesing) loadExcelSheet(f *excelize.File, targetSheet string) (*table, error) {
+func (e *exceltesing) loadExcelSheet(wb *workbook, targetSheet string) (*table, error) {
+	s, err := wb.sheet(targetSheet)
+	if err != nil {
+		return nil, err
+	}
+	return s.table, nil
+}
+
+// sheet はexceltesting形式のシートを解析した結果です。
+type sheet struct {
+	// シート名
+	name string
+	// シートフォーマットのバージョン
+	version string
+	// テーブル論理名
+	logicalName string
+	// カラム論理名。table.columns と同じ順序です
+	logicalColumns []string
+	// データ行のA列の値。table.data と同じ順序です
+	labels []string
+	// データ行のExcel上の行番号(1始まり)。table.data と同じ順序です
+	rowNums []int
+	// カラム物理名を記載した行の行番号(1始まり)。データ行はこの次の行からです
+	columnDefineRowNum int
+	// 3行目に記載されたシート単位の設定
+	options map[string]string
+	table   *table
+}
+
+func parseSheet(f *excelize.File, targetSheet string) (*sheet, error) {
 	var (
+		logicalNmCell      = "A1"
 		tableNmCell        = "A2"
 		columnDefineRowNum = 9
 	)
@@ -308,65 +649,195 @@ func (e *exceltesing) loadExcelSheet(f *excelize.File, targetSheet string) (*tab
 	if tableNm == "" {
 		return nil, fmt.Errorf("table name is empty")
 	}
+	logicalNm, err := f.GetCellValue(targetSheet, logicalNmCell)
+	if err != nil {
+		return nil, fmt.Errorf("get cell value: %w", err)
+	}
 
 	rows, err := f.GetRows(targetSheet)
 	if err != nil {
 		return nil, fmt.Errorf("get row: %w", err)
 	}
+	if len(rows) < columnDefineRowNum {
+		return nil, fmt.Errorf("column define row(%d) is not found", columnDefineRowNum)
+	}
 
 	columns := getExcelColumns(rows, columnDefineRowNum)
-	data, err := getExcelData(rows, columnDefineRowNum)
+	data, rowNums, err := getExcelData(rows, columnDefineRowNum)
 	if err != nil {
 		return nil, fmt.Errorf("get excel data: %w", err)
 	}
 
-	return &table{
-		name:    tableNm,
-		columns: columns,
-		data:    data,
+	labels := make([]string, 0, len(rowNums))
+	for _, n := range rowNums {
+		labels = append(labels, rows[n-1][0])
+	}
+
+	return &sheet{
+		name:               targetSheet,
+		version:            formatVersion,
+		logicalName:        logicalNm,
+		logicalColumns:     getExcelLogicalColumns(rows, columnDefineRowNum),
+		labels:             labels,
+		rowNums:            rowNums,
+		options:            extractSheetOptions(rows),
+		columnDefineRowNum: columnDefineRowNum,
+		table: &table{
+			name:    tableNm,
+			columns: columns,
+			data:    data,
+		},
 	}, nil
 }
 
+// comparative は比較可能な値として取得した、実際のテーブルの値と期待値です。
+type comparative struct {
+	// 比較対象のカラム
+	columns []string
+	// 比較対象のカラムの型名。columns と同じ順序です
+	types []string
+	// 行を突き合わせるキーとなるカラム。キーが存在しない場合は nil です
+	key []string
+	// 実際のテーブルの値
+	got [][]x
+	// 期待値
+	want [][]x
+	// 期待値の各行のExcel上の行番号
+	wantRowNums []int
+	// カラムごとの比較関数。指定がないカラムは nil です
+	comparers []ValueComparer
+	// go-cmp で比較する場合のオプション
+	opts []cmp.Option
+}
+
 // comparativeSource はデータベースに格納されている実際のテーブルの値と、Excelから取得した期待する結果の値を
 // 比較可能な値として取得します。
-func (e *exceltesing) comparativeSource(t *table, req *CompareRequest) ([][]x, [][]x, error) {
-	var pk string
-	err := e.db.QueryRow(getPrimaryKeyQuery, t.name).Scan(&pk)
+func (e *exceltesing) comparativeSource(s *sheet, req *CompareRequest) (*comparative, error) {
+	t := s.table
+	orderBy, err := e.comparingOrder(s, req)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	q1, cs, err := e.buildComparingQuery(t, pk, req)
+	expected, matchers, err := extractMatchers(t, req.CellNormalization)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("parse matcher: %w", err)
+	}
+	// 行を突き合わせるキーは値で比較するため、matcher を記載できない
+	for _, c := range matcherColumns(t, matchers) {
+		if slices.Contains(orderBy, c) {
+			return nil, fmt.Errorf("matcher can not be used for order column %s", c)
+		}
 	}
 
-	got, err := e.getComparingData(q1, len(cs))
+	q, cs, err := e.buildComparingQuery(t, orderBy, comparingFilter(s, req), req)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	if err := e.createTempTable(t.name); err != nil {
-		return nil, nil, fmt.Errorf("create temporary table: %w", err)
+	got, err := e.getComparingData(q, len(cs))
+	if err != nil {
+		return nil, err
 	}
 
-	c := t.DeepCopy()
-	c.name = tempTablePrefix + c.name
-	if err := e.insertData(&c); err != nil {
-		return nil, nil, fmt.Errorf("insert data to %s: %w", c.name, err)
+	types, loc, err := e.columnTypes(t.name)
+	if err != nil {
+		return nil, fmt.Errorf("get column types: %w", err)
 	}
 
-	q2, _, err := e.buildComparingQuery(&c, pk, req)
-	if err != nil {
-		return nil, nil, err
+	// 期待値は Go で変換できない型のみ、カラムごとにまとめてデータベースでカラムの型に変換する
+	wantX := make([][]x, len(expected.data))
+	casts := make(map[string][]int)
+	for i, row := range expected.data {
+		wantX[i] = make([]x, len(cs))
+		for j, column := range cs {
+			idx := slices.Index(t.columns, column)
+			if matchers[i] != nil && matchers[i][idx] != nil {
+				m := matchers[i][idx]
+				if now, ok := m.(nowMatcher); ok && types[column].name == "timestamp" && !types[column].domain {
+					m = now.inZone(loc)
+				}
+				wantX[i][j] = x{column: column, value: m}
+				continue
+			}
+			v, err := normalizeValue(row[idx], types[column])
+			if errors.Is(err, errUnsupportedType) {
+				casts[column] = append(casts[column], i)
+				wantX[i][j] = x{column: column}
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("column %s at row %d: %w", column, s.rowNums[i], err)
+			}
+			wantX[i][j] = x{column: column, value: v}
+		}
+	}
+	for j, column := range cs {
+		rows, ok := casts[column]
+		if !ok {
+			continue
+		}
+		idx := slices.Index(t.columns, column)
+		cells := make([]string, len(rows))
+		for k, i := range rows {
+			cells[k] = expected.data[i][idx]
+		}
+		values, err := e.castValues(cells, types[column])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		for _, i := range rows {
+			wantX[i][j].value = values[expected.data[i][idx]]
+		}
 	}
 
-	want, err := e.getComparingData(q2, len(cs))
-	if err != nil {
-		return nil, nil, err
+	typeNames := make([]string, len(cs))
+	for i, column := range cs {
+		typeNames[i] = types[column].name
 	}
 
-	return convert(got, cs), convert(want, cs), nil
+	return &comparative{
+		columns:     cs,
+		types:       typeNames,
+		key:         orderBy,
+		got:         convert(got, cs),
+		want:        wantX,
+		wantRowNums: s.rowNums,
+		comparers:   columnComparers(t.name, cs, types, req),
+		opts:        append(defaultCmpOptions(), req.CmpOptions...),
+	}, nil
+}
+
+// comparingFilter は実際の値を取得する際の絞り込み条件を返します。
+// シートの where、CompareRequest.TableOptions の Where の順に優先します。
+func comparingFilter(s *sheet, req *CompareRequest) string {
+	if v := s.options[sheetOptionWhere]; v != "" {
+		return v
+	}
+	return req.TableOptions[s.table.name].Where
+}
+
+// comparingOrder は実際の値と期待値を突き合わせるための並び順を返します。
+// シートの order_by、CompareRequest.TableOptions の OrderBy、主キーの順に優先します。
+// いずれも存在しない場合は nil を返し、比較対象の全カラムで並び替えます。
+func (e *exceltesing) comparingOrder(s *sheet, req *CompareRequest) ([]string, error) {
+	if v := s.options[sheetOptionOrderBy]; v != "" {
+		return splitColumns(v), nil
+	}
+	if o, ok := req.TableOptions[s.table.name]; ok && len(o.OrderBy) > 0 {
+		return o.OrderBy, nil
+	}
+
+	var pk string
+	err := e.db.QueryRowContext(context.TODO(), getPrimaryKeyQuery, s.table.name).Scan(&pk)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 主キーが存在しないテーブルやビュー
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return splitColumns(pk), nil
 }
 
 func (e *exceltesing) insertData(t *table) error {
@@ -383,13 +854,11 @@ func (e *exceltesing) insertData(t *table) error {
 	return err
 }
 
-func (e *exceltesing) createTempTable(tableName string) error {
-	query := fmt.Sprintf("CREATE TEMP TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 0 = 1;", tempTablePrefix+tableName, tableName)
-	_, err := e.db.Exec(query)
-	return err
-}
-
-func (e *exceltesing) buildComparingQuery(t *table, primaryKey string, req *CompareRequest) (string, []string, error) {
+// buildComparingQuery は比較対象のカラムを orderBy の順に取得するクエリを作成します。
+// 並び順を一意にするため、orderBy 以外の比較対象のカラムも並び順に加えます。
+// 主キーが存在しない場合に重複した行も比較できるよう、テキスト表現で並び替えます。
+// where を指定した場合は WHERE 句として条件に一致する行のみ取得します。
+func (e *exceltesing) buildComparingQuery(t *table, orderBy []string, where string, req *CompareRequest) (string, []string, error) {
 	columns := make([]string, 0, len(t.columns))
 	for _, c := range t.columns {
 		if slices.Contains(req.IgnoreColumns, c) {
@@ -398,6 +867,15 @@ func (e *exceltesing) buildComparingQuery(t *table, primaryKey string, req *Comp
 		columns = append(columns, c)
 	}
 
+	orders := make([]string, 0, len(columns))
+	orders = append(orders, orderBy...)
+	for _, c := range columns {
+		if slices.Contains(orderBy, c) {
+			continue
+		}
+		orders = append(orders, c+"::text")
+	}
+
 	var querySQL string
 	querySQL += "SELECT "
 	for i, column := range columns {
@@ -406,14 +884,18 @@ func (e *exceltesing) buildComparingQuery(t *table, primaryKey string, req *Comp
 		}
 		querySQL += column
 	}
-	querySQL += fmt.Sprintf(" FROM %s ORDER BY %s;", t.name, primaryKey)
+	querySQL += fmt.Sprintf(" FROM %s", t.name)
+	if where != "" {
+		querySQL += fmt.Sprintf(" WHERE (%s)", where)
+	}
+	querySQL += fmt.Sprintf(" ORDER BY %s;", strings.Join(orders, ", "))
 	return querySQL, columns, nil
 }
 
 func (e *exceltesing) getComparingData(q string, len int) ([][]any, error) {
 	var got [][]any
 
-	rows, err := e.db.Query(q)
+	rows, err := e.db.QueryContext(context.TODO(), q)
 	if err != nil {
 		return nil, err
 	}
@@ -444,7 +926,7 @@ type dbColumn struct {
 func (e *exceltesing) tableColumns(tableName string) ([]dbColumn, error) {
 	var columns []dbColumn
 
-	rows, err := e.db.Query(getTableNotNullColumns, tableName)
+	rows, err := e.db.QueryContext(context.TODO(), getTableNotNullColumns, tableName)
 	if err != nil {
 		return nil, err
 	}
@@ -479,10 +961,40 @@ func getExcelColumns(rows [][]string, rowNum int) []string {
 	return columns
 }
 
-func getExcelData(rows [][]string, rowNum int) ([][]string, error) {
+// getExcelLogicalColumns はカラム物理名の1行上に記載されたカラム論理名を、
+// getExcelColumns で取得したカラムと同じ順序で取得します。
+func getExcelLogicalColumns(rows [][]string, rowNum int) []string {
+	columns := make([]string, 0, len(rows[rowNum-1]))
+	if rowNum < 2 {
+		return columns
+	}
+
+	logicalRow := rows[rowNum-2]
+	for i, cell := range rows[rowNum-1] {
+		if i == 0 {
+			continue
+		}
+		if strings.Trim(strings.Trim(cell, "　"), " ") == "" {
+			continue
+		}
+		var logical string
+		if i < len(logicalRow) {
+			logical = strings.Trim(strings.Trim(logicalRow[i], "　"), " ")
+		}
+		columns = append(columns, logical)
+	}
+
+	return columns
+}
+
+// getExcelData はデータ行と、各データ行のExcel上の行番号(1始まり)を取得します。
+func getExcelData(rows [][]string, rowNum int) ([][]string, []int, error) {
 	columns := getExcelColumns(rows, rowNum)
 
-	var data [][]string
+	var (
+		data    [][]string
+		rowNums []int
+	)
 	for i, row := range rows[rowNum:] {
 		rowStr := ""
 		for _, cell := range row {
@@ -492,15 +1004,16 @@ func getExcelData(rows [][]string, rowNum int) ([][]string, error) {
 			continue
 		}
 		if len(row) < len(columns) {
-			return nil, fmt.Errorf("data size is smaller than defines. columns: %s row: %s data: %+v\n", fmt.Sprint(len(row)), fmt.Sprint(i+1), row)
+			return nil, nil, fmt.Errorf("data size is smaller than defines. columns: %s row: %s data: %+v\n", fmt.Sprint(len(row)), fmt.Sprint(i+1), row)
 		}
 		// 1列目が空ならskip
 		if row[0] == "" {
 			continue
 		}
 		data = append(data, row[1:len(columns)+1])
+		rowNums = append(rowNums, rowNum+i+1)
 	}
-	return data, nil
+	return data, rowNums, nil
 }
 
 func getFileNameWithoutExt(path string) string {
@@ -529,6 +1042,63 @@ func convert(vs [][]any, columns []string) [][]x {
 	return resp
 }
 
+// validateKeyColumns は行を突き合わせるキーのカラムが、比較対象のカラムに含まれているか検証します。
+func validateKeyColumns(t *table, key []string, req *CompareRequest) error {
+	if len(key) == 0 {
+		return fmt.Errorf("primary key or order_by is required")
+	}
+	for _, k := range key {
+		if !slices.Contains(t.columns, k) || slices.Contains(req.IgnoreColumns, k) {
+			return fmt.Errorf("key column %s must be compared", k)
+		}
+	}
+	return nil
+}
+
+// rowKey は行のキーとなるカラムの値を連結した文字列を返します。
+func rowKey(row []x, key []string) string {
+	values := make([]string, 0, len(key))
+	for _, k := range key {
+		for _, v := range row {
+			if v.column == k {
+				values = append(values, formatValue(v.value))
+				break
+			}
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+// describeKey は行のキーを company_cd=00001 のような形式で返します。
+func describeKey(row []x, key []string) string {
+	values := make([]string, 0, len(key))
+	for _, k := range key {
+		for _, v := range row {
+			if v.column == k {
+				values = append(values, fmt.Sprintf("%s=%s", k, formatValue(v.value)))
+				break
+			}
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// filterRowsByKey は rows のうち、keyRows のいずれかの行とキーが一致する行を返します。
+func filterRowsByKey(rows, keyRows [][]x, key []string) [][]x {
+	keys := make(map[string]struct{}, len(keyRows))
+	for _, row := range keyRows {
+		keys[rowKey(row, key)] = struct{}{}
+	}
+
+	var filtered [][]x
+	for _, row := range rows {
+		if _, ok := keys[rowKey(row, key)]; ok {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
 // extractSheetFormatVersion is extracting exceltesting sheet format version.
 // default 1.0
 func extractSheetFormatVersion(f *excelize.File, sheet string) string {
@@ -550,9 +1120,51 @@ func extractSheetFormatVersion(f *excelize.File, sheet string) string {
 		return "1.0"
 	}
 
-	if strings.TrimSpace(strings.ToLower(row[0])) == "version" {
+	if strings.TrimSpace(strings.ToLower(row[0])) == sheetOptionVersion {
 		return strings.TrimSpace(row[1])
 	}
 
 	return "1.0"
 }
+
+const (
+	// sheetOptionVersion はシートフォーマットのバージョンです
+	sheetOptionVersion = "version"
+	// sheetOptionOrderBy は比較時の並び順に用いるカラム名です
+	sheetOptionOrderBy = "order_by"
+	// sheetOptionMode は比較方法です
+	sheetOptionMode = "mode"
+	// sheetOptionWhere は比較時の絞り込み条件です
+	sheetOptionWhere = "where"
+)
+
+// extractSheetOptions はシートの3行目に記載されたシート単位の設定を取得します。
+// 設定は A3:B3, C3:D3 ... のように、キーと値を隣り合うセルに記載します。
+// キーは大文字小文字を区別しません。
+func extractSheetOptions(rows [][]string) map[string]string {
+	options := make(map[string]string)
+	if len(rows) < 3 {
+		return options
+	}
+
+	row := rows[2] // 3行目に記載があるとする
+	for i := 0; i+1 < len(row); i += 2 {
+		key := strings.TrimSpace(strings.ToLower(row[i]))
+		if key == "" {
+			continue
+		}
+		options[key] = strings.TrimSpace(row[i+1])
+	}
+	return options
+}
+
+// splitColumns はカンマ区切りのカラム名を分割します。
+func splitColumns(s string) []string {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
@@ -1,11 +1,14 @@
 package exceltesting
 
 import (
+	"context"
 	"database/sql"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -173,9 +176,6 @@ func Test_exceltesing_Compare(t *testing.T) {
 
 	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
 
-	// Even if there is a difference in Compare(), t.Errorf() prevents the test from failing.
-	mockT := new(testing.T)
-
 	tests := []struct {
 		name      string
 		input     func(t *testing.T)
@@ -297,7 +297,8 @@ func Test_exceltesing_Compare(t *testing.T) {
 			tt.input(t)
 
 			e := New(conn)
-			got := e.Compare(mockT, CompareRequest{
+			// Compare() reports differences to t as subtests, so use CompareWithContext() to check the result.
+			got, _ := e.CompareWithContext(context.Background(), CompareRequest{
 				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
 				SheetPrefix:    tt.wantSheet,
 				IgnoreSheet:    nil,
@@ -311,6 +312,525 @@ func Test_exceltesing_Compare(t *testing.T) {
 	}
 }
 
+func Test_exceltesing_Compare_reporter(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	if _, err := conn.Exec(`DROP TABLE IF EXISTS report_a, report_b;
+		CREATE TABLE report_a (id int PRIMARY KEY, v text); INSERT INTO report_a VALUES (1, 'a');
+		CREATE TABLE report_b (id int PRIMARY KEY, v text); INSERT INTO report_b VALUES (1, 'b');`); err != nil {
+		t.Fatal(err)
+	}
+	book := filepath.Join(t.TempDir(), "report.xlsx")
+	writeTestBook(t, book, []testSheet{
+		{name: "A", table: "report_a", cols: []string{"id", "v"}, data: [][]string{{"1", "1", "x"}}},
+		{name: "B", table: "report_b", cols: []string{"id", "v"}, data: [][]string{{"1", "1", "y"}}},
+	})
+
+	// シート A の差分を報告した後も、シート B を比較する
+	r := &fakeReporter{}
+	if New(conn).Compare(r, CompareRequest{TargetBookPath: book}) {
+		t.Errorf("Compare() should return false")
+	}
+	if len(r.fatals) > 0 {
+		t.Errorf("Compare() should not report fatal errors: %v", r.fatals)
+	}
+	if len(r.errors) != 2 {
+		t.Fatalf("Compare() should report 2 errors but %d: %v", len(r.errors), r.errors)
+	}
+	for i, prefix := range []string{"sheet = A: ", "sheet = B: "} {
+		if !strings.HasPrefix(r.errors[i], prefix) {
+			t.Errorf("error %d should start with %q: %s", i, prefix, r.errors[i])
+		}
+	}
+}
+
+func Test_exceltesing_Load_filteredRun(t *testing.T) {
+	if os.Getenv("EXCELTESTING_LOAD_FILTERED_RUN") == "1" {
+		conn := testonly.OpenTestDB(t)
+		defer conn.Close()
+
+		book := filepath.Join(t.TempDir(), "filtered.xlsx")
+		writeTestBook(t, book, []testSheet{
+			{name: "A", table: "filtered_a", cols: []string{"id"}, data: [][]string{{"1", "1"}}},
+			{name: "B", table: "filtered_b", cols: []string{"id"}, data: [][]string{{"1", "1"}}},
+		})
+		New(conn).Load(t, LoadRequest{TargetBookPath: book})
+		for _, table := range []string{"filtered_a", "filtered_b"} {
+			var n int
+			if err := conn.QueryRow(`SELECT count(*) FROM ` + table).Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Errorf("table %s should be loaded but has %d rows", table, n)
+			}
+		}
+		return
+	}
+
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+	if _, err := conn.Exec(`DROP TABLE IF EXISTS filtered_a, filtered_b;
+		CREATE TABLE filtered_a (id int PRIMARY KEY); CREATE TABLE filtered_b (id int PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+
+	// go test -run でサブテストを絞り込んでも、すべてのシートを投入する
+	cmd := exec.Command(os.Args[0], "-test.run", "^"+t.Name()+"$/^A$", "-test.v")
+	cmd.Env = append(os.Environ(), "EXCELTESTING_LOAD_FILTERED_RUN=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("filtered test failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: "+t.Name()) {
+		t.Errorf("filtered test should run %s:\n%s", t.Name(), out)
+	}
+}
+
+func Test_exceltesing_Compare_withoutPrimaryKey(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	tests := []struct {
+		name      string
+		input     string
+		wantSheet string
+		equal     bool
+	}{
+		{
+			name: "equal table without primary key",
+			input: `INSERT INTO company_log (company_cd,message,logged_at)
+				VALUES ('00002','updated','2022-01-02 00:00:00+09'),('00001','created','2022-01-01 00:00:00+09'),('00001','created','2022-01-01 00:00:00+09');`,
+			wantSheet: "会社ログ",
+			equal:     true,
+		},
+		{
+			name: "diff duplicated rows of table without primary key",
+			input: `INSERT INTO company_log (company_cd,message,logged_at)
+				VALUES ('00002','updated','2022-01-02 00:00:00+09'),('00001','created','2022-01-01 00:00:00+09'),('00002','updated','2022-01-02 00:00:00+09');`,
+			wantSheet: "会社ログ",
+			equal:     false,
+		},
+		{
+			name: "equal view",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00002','YDC',1972,current_timestamp,current_timestamp,1),('00001','Future',1989,current_timestamp,current_timestamp,1);`,
+			wantSheet: "会社ビュー",
+			equal:     true,
+		},
+		{
+			name: "diff view",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1);`,
+			wantSheet: "会社ビュー",
+			equal:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Exec(`TRUNCATE company, company_log;`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(tt.input); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(conn)
+			got, errs := e.CompareWithContext(context.Background(), CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare_nopk.xlsx"),
+				SheetPrefix:    tt.wantSheet,
+			})
+			if got != tt.equal {
+				t.Errorf("CompareWithContext() should return %v but %v: %v", tt.equal, got, errs)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_Compare_matcher(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	tests := []struct {
+		name  string
+		input string
+		equal bool
+	}{
+		{
+			name: "matched",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,3),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			equal: true,
+		},
+		{
+			name: "unmatched regex",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','YDC',1989,current_timestamp,current_timestamp,3),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			equal: false,
+		},
+		{
+			name: "unmatched approx",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',2000,current_timestamp,current_timestamp,3),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			equal: false,
+		},
+		{
+			name: "unmatched now",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,'2022-01-01 00:00:00+09',current_timestamp,3),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			equal: false,
+		},
+		{
+			name: "cells without matcher are compared strictly",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,3),('00002','YDC',1972,current_timestamp,current_timestamp,2);`,
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(tt.input); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(conn)
+			got, errs := e.CompareWithContext(context.Background(), CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare_matcher.xlsx"),
+			})
+			if got != tt.equal {
+				t.Errorf("CompareWithContext() should return %v but %v: %v", tt.equal, got, errs)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_Compare_matcherNowTimestamp(t *testing.T) {
+	db := testonly.OpenTestDB(t)
+	defer db.Close()
+
+	testonly.ExecSQLFile(t, db, filepath.Join("testdata", "schema", "ddl.sql"))
+	New(db).Load(t, LoadRequest{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-"})
+
+	// timestamp のカラムはセッションのタイムゾーンの現在時刻と比較する
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SET TIME ZONE 'Asia/Tokyo';`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `UPDATE test_x SET id = 'now', s = localtimestamp;`); err != nil {
+		t.Fatal(err)
+	}
+
+	book := filepath.Join(t.TempDir(), "now.xlsx")
+	writeTestBook(t, book, []testSheet{{
+		name:  "テストx",
+		table: "test_x",
+		cols:  []string{"id", "s"},
+		data:  [][]string{{"1", "now", "<NOW±1m>"}},
+	}})
+	if equal, errs := New(conn).CompareWithContext(ctx, CompareRequest{TargetBookPath: book}); !equal {
+		t.Errorf("timestamp column should match <NOW±1m>: %v", errs)
+	}
+}
+
+func Test_exceltesing_Compare_mode(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	tests := []struct {
+		name  string
+		input string
+		r     CompareRequest
+		equal bool
+	}{
+		{
+			name: "contains",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "含む"},
+			equal: true,
+		},
+		{
+			name: "contains with different value",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,2),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "含む"},
+			equal: false,
+		},
+		{
+			name: "contains with missing row",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "含む"},
+			equal: false,
+		},
+		{
+			name: "absent",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "存在しない"},
+			equal: true,
+		},
+		{
+			name: "absent with existing row",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00009','Other',2000,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "存在しない"},
+			equal: false,
+		},
+		{
+			name: "filtered by where",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,2),('00002','YDC',1972,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "絞り込み"},
+			equal: true,
+		},
+		{
+			name: "filtered by where with unexpected row",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00002','YDC',1972,current_timestamp,current_timestamp,1),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`,
+			r:     CompareRequest{TargetBookPath: filepath.Join("testdata", "compare_mode.xlsx"), SheetPrefix: "絞り込み"},
+			equal: false,
+		},
+		{
+			name: "filtered by where of table option",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`,
+			r: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				IgnoreColumns:  []string{"created_at", "updated_at"},
+				TableOptions:   map[string]CompareTableOption{"company": {Where: "founded_year < 2000"}},
+			},
+			equal: true,
+		},
+		{
+			name: "contains by request",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`,
+			r: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				IgnoreColumns:  []string{"created_at", "updated_at"},
+				Mode:           CompareModeContains,
+			},
+			equal: true,
+		},
+		{
+			name: "exact by table option overrides request",
+			input: `INSERT INTO company (company_cd,company_name,founded_year,created_at,updated_at,revision)
+				VALUES ('00001','Future',1989,current_timestamp,current_timestamp,1),('00002','YDC',1972,current_timestamp,current_timestamp,1),('00003','FutureOne',2002,current_timestamp,current_timestamp,1);`,
+			r: CompareRequest{
+				TargetBookPath: filepath.Join("testdata", "compare.xlsx"),
+				IgnoreColumns:  []string{"created_at", "updated_at"},
+				Mode:           CompareModeContains,
+				TableOptions:   map[string]CompareTableOption{"company": {Mode: CompareModeExact}},
+			},
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := conn.Exec(`TRUNCATE company;`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.Exec(tt.input); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(conn)
+			got, errs := e.CompareWithContext(context.Background(), tt.r)
+			if got != tt.equal {
+				t.Errorf("CompareWithContext() should return %v but %v: %v", tt.equal, got, errs)
+			}
+		})
+	}
+}
+
+func Test_filterRowsByKey(t *testing.T) {
+	rows := [][]x{
+		{{column: "company_cd", value: "00001"}, {column: "revision", value: int64(1)}},
+		{{column: "company_cd", value: "00002"}, {column: "revision", value: int64(1)}},
+		{{column: "company_cd", value: "00003"}, {column: "revision", value: int64(1)}},
+	}
+	keyRows := [][]x{
+		{{column: "company_cd", value: "00003"}},
+		{{column: "company_cd", value: "00001"}},
+	}
+	want := [][]x{rows[0], rows[2]}
+	if diff := cmp.Diff(want, filterRowsByKey(rows, keyRows, []string{"company_cd"}), cmp.AllowUnexported(x{})); diff != "" {
+		t.Errorf("filterRowsByKey() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_exceltesing_partitionTable(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	e := New(conn)
+	book := filepath.Join("testdata", "partition.xlsx")
+
+	// パーティションテーブルへの投入は全パーティションに振り分けられる
+	e.Load(t, LoadRequest{TargetBookPath: book, SheetPrefix: "気温"})
+	if equal, errs := e.CompareWithContext(context.Background(), CompareRequest{TargetBookPath: book, SheetPrefix: "気温"}); !equal {
+		t.Errorf("partitioned table should be equal: %v", errs)
+	}
+	if equal, errs := e.CompareWithContext(context.Background(), CompareRequest{TargetBookPath: book, SheetPrefix: "パーティション"}); !equal {
+		t.Errorf("partition should be equal: %v", errs)
+	}
+
+	// パーティションを指定した投入は他のパーティションに影響しない
+	if _, err := conn.Exec(`UPDATE temperature SET value = 0 WHERE ymd = '20210228';`); err != nil {
+		t.Fatal(err)
+	}
+	e.Load(t, LoadRequest{TargetBookPath: book, SheetPrefix: "パーティション"})
+	if equal, errs := e.CompareWithContext(context.Background(), CompareRequest{TargetBookPath: book, SheetPrefix: "気温"}); !equal {
+		t.Errorf("partitioned table should be equal: %v", errs)
+	}
+}
+
+func Test_exceltesing_Load_skipUnchanged(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+	if _, err := conn.Exec(`DROP TABLE IF EXISTS exceltesting_load_ledger;`); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(conn)
+	r := LoadRequest{
+		TargetBookPath:      filepath.Join("testdata", "load.xlsx"),
+		SheetPrefix:         "normal-",
+		EnableSkipUnchanged: true,
+	}
+	xmin := func() string {
+		t.Helper()
+		var v string
+		if err := conn.QueryRow(`SELECT string_agg(xmin::text, ',' ORDER BY id) FROM test_x;`).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	e.Load(t, r)
+	loaded := xmin()
+
+	// 変更されていないテーブルは投入しない
+	e.Load(t, r)
+	if got := xmin(); got != loaded {
+		t.Errorf("unchanged table should not be reloaded: xmin %s -> %s", loaded, got)
+	}
+
+	// 投入後に変更されたテーブルは投入し直す
+	if _, err := conn.Exec(`UPDATE test_x SET c = 'z';`); err != nil {
+		t.Fatal(err)
+	}
+	e.Load(t, r)
+	got, err := getTestX(t, conn)
+	if err != nil {
+		t.Fatalf("failed to get test_x: %v", err)
+	}
+	if len(got) != 1 || got[0].C != "a" {
+		t.Errorf("modified table should be reloaded: %+v", got)
+	}
+
+	// 投入前に開始したトランザクションが投入後に変更した場合も投入し直す
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT txid_current();`); err != nil {
+		t.Fatal(err)
+	}
+	e.Load(t, r)
+	if _, err := tx.Exec(`UPDATE test_x SET c = 'z';`); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	e.Load(t, r)
+	got, err = getTestX(t, conn)
+	if err != nil {
+		t.Fatalf("failed to get test_x: %v", err)
+	}
+	if len(got) != 1 || got[0].C != "a" {
+		t.Errorf("table modified by an older transaction should be reloaded: %+v", got)
+	}
+}
+
+func Test_exceltesing_buildComparingQuery(t *testing.T) {
+	tb := &table{
+		name:    "company",
+		columns: []string{"company_cd", "company_name", "created_at"},
+	}
+	tests := []struct {
+		name    string
+		orderBy []string
+		where   string
+		want    string
+	}{
+		{
+			name:    "order by primary key",
+			orderBy: []string{"company_cd"},
+			want:    "SELECT company_cd, company_name FROM company ORDER BY company_cd, company_name::text;",
+		},
+		{
+			name:    "order by all columns",
+			orderBy: nil,
+			want:    "SELECT company_cd, company_name FROM company ORDER BY company_cd::text, company_name::text;",
+		},
+		{
+			name:    "filtered by where",
+			orderBy: []string{"company_cd"},
+			where:   "company_cd LIKE '0000%'",
+			want:    "SELECT company_cd, company_name FROM company WHERE (company_cd LIKE '0000%') ORDER BY company_cd, company_name::text;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &exceltesing{}
+			got, _, err := e.buildComparingQuery(tb, tt.orderBy, tt.where, &CompareRequest{IgnoreColumns: []string{"created_at"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("buildComparingQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_extractSheetOptions(t *testing.T) {
+	rows := [][]string{
+		{"会社"},
+		{"company"},
+		{"Version", "2.0", " ORDER_BY ", "company_cd, company_name", "", ""},
+	}
+	want := map[string]string{
+		"version":  "2.0",
+		"order_by": "company_cd, company_name",
+	}
+	if diff := cmp.Diff(want, extractSheetOptions(rows)); diff != "" {
+		t.Errorf("extractSheetOptions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 type testX struct {
 	ID string
 	A  bool
@@ -426,11 +946,23 @@ func Test_exceltesing_DumpCSV(t *testing.T) {
 				filepath.Join("testdata", "csv", "dumpWithEmptyFileMultipleSheets_会社3.csv"),
 			},
 		},
+		{
+			name: "dumped sheet format version 2.0",
+			args: args{r: DumpRequest{TargetBookPaths: []string{filepath.Join("testdata", "load_v2.xlsx")}}},
+			want: []string{
+				filepath.Join("testdata", "want_load_v2_normal-テストx.csv"),
+				filepath.Join("testdata", "want_load_v2_option-テストx.csv"),
+			},
+			got: []string{
+				filepath.Join("testdata", "csv", "load_v2_normal-テストx.csv"),
+				filepath.Join("testdata", "csv", "load_v2_option-テストx.csv"),
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			e := &exceltesing{nil}
+			e := &exceltesing{}
 			e.DumpCSV(t, tt.args.r)
 
 			for i := 0; i < len(tt.want); i++ {
@@ -460,3 +992,48 @@ func Test_exceltesing_DumpCSV(t *testing.T) {
 		})
 	}
 }
+
+func Test_dumpBookAsCSV(t *testing.T) {
+	outDir := t.TempDir()
+	book := filepath.Join("testdata", "dump.xlsx")
+
+	// 削除されたシートのCSVファイル
+	stale := filepath.Join(outDir, "dump_削除済みシート.csv")
+	if err := os.WriteFile(stale, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{book}, OutputDir: outDir, Check: true}); err == nil {
+		t.Errorf("dumpBookAsCSV() with check mode should return error before dump")
+	}
+
+	if err := dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{book}, OutputDir: outDir}); err != nil {
+		t.Fatalf("dumpBookAsCSV() error = %v", err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed: %v", stale, err)
+	}
+	b1, err := os.ReadFile(filepath.Join("testdata", "want_dump_会社.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := os.ReadFile(filepath.Join(outDir, "dump_会社.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b1, b2); diff != "" {
+		t.Errorf("dumped csv mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{book}, OutputDir: outDir, Check: true}); err != nil {
+		t.Errorf("dumpBookAsCSV() with check mode error = %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(outDir, "dump_会社.csv"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dumpBookAsCSV(DumpRequest{TargetBookPaths: []string{book}, OutputDir: outDir, Check: true}); err == nil {
+		t.Errorf("dumpBookAsCSV() with check mode should return error for modified csv")
+	}
+}
@@ -0,0 +1,199 @@
+// Package sqlscript は PostgreSQL のSQLスクリプトを文に分割して実行します。
+package sqlscript
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Statement はSQLスクリプトに記載された1つの文です。
+type Statement struct {
+	// SQL は末尾のセミコロンを除いた文です
+	SQL string
+	// Line は文の開始位置のスクリプト上の行番号(1始まり)です
+	Line int
+}
+
+// Split はSQLスクリプトをセミコロンで文に分割します。
+//
+// 文字列リテラル('...', E'...')、引用符で囲んだ識別子("...")、ドル引用符で囲んだ文字列($$...$$, $tag$...$tag$)、
+// コメント(-- ..., /* ... */)の中のセミコロンでは分割しません。コメントのみの文は含みません。
+func Split(script string) ([]Statement, error) {
+	var (
+		stmts     []Statement
+		line      = 1
+		start     = -1
+		startLine int
+	)
+	begin := func(i int) {
+		if start < 0 {
+			start, startLine = i, line
+		}
+	}
+
+	n := len(script)
+	for i := 0; i < n; {
+		c := script[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < n && script[i+1] == '-':
+			if j := strings.IndexByte(script[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = n
+			}
+		case c == '/' && i+1 < n && script[i+1] == '*':
+			from := line
+			depth := 0
+			for i < n {
+				if script[i] == '/' && i+1 < n && script[i+1] == '*' {
+					depth++
+					i += 2
+					continue
+				}
+				if script[i] == '*' && i+1 < n && script[i+1] == '/' {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+					continue
+				}
+				if script[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			if depth > 0 {
+				return nil, fmt.Errorf("line %d: unterminated block comment", from)
+			}
+		case c == ';':
+			if start >= 0 {
+				stmts = append(stmts, Statement{SQL: strings.TrimSpace(script[start:i]), Line: startLine})
+				start = -1
+			}
+			i++
+		case c == '\'':
+			begin(i)
+			// E'...' はバックスラッシュでエスケープする
+			escape := i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i < 2 || !isIdentChar(script[i-2]))
+			end, lines, ok := scanQuoted(script, i, '\'', escape)
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated quoted string", line)
+			}
+			i, line = end, line+lines
+		case c == '"':
+			begin(i)
+			end, lines, ok := scanQuoted(script, i, '"', false)
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated quoted identifier", line)
+			}
+			i, line = end, line+lines
+		case c == '$':
+			begin(i)
+			tag := dollarTag(script, i)
+			if tag == "" {
+				i++
+				continue
+			}
+			j := strings.Index(script[i+len(tag):], tag)
+			if j < 0 {
+				return nil, fmt.Errorf("line %d: unterminated dollar-quoted string", line)
+			}
+			end := i + len(tag) + j + len(tag)
+			line += strings.Count(script[i:end], "\n")
+			i = end
+		default:
+			begin(i)
+			i++
+		}
+	}
+	if start >= 0 {
+		stmts = append(stmts, Statement{SQL: strings.TrimSpace(script[start:]), Line: startLine})
+	}
+	return stmts, nil
+}
+
+// scanQuoted は i の位置の引用符で始まる文字列の終わりの位置と、含まれる改行の数を返します。
+// 引用符を2つ続けた場合は引用符そのものとして扱います。
+func scanQuoted(s string, i int, quote byte, backslash bool) (end, lines int, ok bool) {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\n':
+			lines++
+		case '\\':
+			if backslash {
+				if j+1 < len(s) && s[j+1] == '\n' {
+					lines++
+				}
+				j++
+			}
+		case quote:
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1, lines, true
+		}
+	}
+	return 0, 0, false
+}
+
+// dollarTag は i の位置から始まるドル引用符のタグ($$ や $body$)を返します。
+// $1 のようなパラメータや、識別子に含まれる $ の場合は空文字を返します。
+func dollarTag(s string, i int) string {
+	if i > 0 && isIdentChar(s[i-1]) {
+		return ""
+	}
+	for j := i + 1; j < len(s); j++ {
+		c := s[j]
+		if c == '$' {
+			return s[i : j+1]
+		}
+		if !isIdentChar(c) || j == i+1 && c >= '0' && c <= '9' {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// DB は SQL を実行するデータベースのインターフェースです。*sql.DB と *sql.Conn が満たします。
+type DB interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Exec はSQLスクリプトを文に分割し、1つのトランザクションで順に実行します。
+// 失敗した場合は name と文の開始位置の行番号をエラーに含めます。
+func Exec(ctx context.Context, db DB, name, script string) error {
+	stmts, err := Split(script)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, st := range stmts {
+		if _, err := tx.ExecContext(ctx, st.SQL); err != nil {
+			return fmt.Errorf("exec sql at %s:%d, query = %s: %w", name, st.Line, st.SQL, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
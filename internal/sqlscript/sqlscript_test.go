@@ -0,0 +1,88 @@
+package sqlscript
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    []Statement
+		wantErr bool
+	}{
+		{
+			name:   "statements",
+			script: "CREATE TABLE a (id int);\n\nINSERT INTO a VALUES (1);\n",
+			want:   []Statement{{SQL: "CREATE TABLE a (id int)", Line: 1}, {SQL: "INSERT INTO a VALUES (1)", Line: 3}},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "SELECT 1;\nSELECT 2",
+			want:   []Statement{{SQL: "SELECT 1", Line: 1}, {SQL: "SELECT 2", Line: 2}},
+		},
+		{
+			name:   "string literal",
+			script: "INSERT INTO a VALUES ('a;b', 'it''s;');\nSELECT 1;",
+			want:   []Statement{{SQL: "INSERT INTO a VALUES ('a;b', 'it''s;')", Line: 1}, {SQL: "SELECT 1", Line: 2}},
+		},
+		{
+			name:   "escape string",
+			script: `SELECT E'\';', e'\\';SELECT 1;`,
+			want:   []Statement{{SQL: `SELECT E'\';', e'\\'`, Line: 1}, {SQL: "SELECT 1", Line: 1}},
+		},
+		{
+			name:   "backslash in standard string",
+			script: `SELECT 'C:\';SELECT 1;`,
+			want:   []Statement{{SQL: `SELECT 'C:\'`, Line: 1}, {SQL: "SELECT 1", Line: 1}},
+		},
+		{
+			name:   "quoted identifier",
+			script: `CREATE TABLE "a;b" ("x""y;" int);`,
+			want:   []Statement{{SQL: `CREATE TABLE "a;b" ("x""y;" int)`, Line: 1}},
+		},
+		{
+			name: "dollar quoted function body",
+			script: "CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at := now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;\n" +
+				"CREATE FUNCTION g() RETURNS text AS $body$ SELECT $$;$$ $body$ LANGUAGE sql;\nSELECT 1;",
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at := now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql", Line: 1},
+				{SQL: "CREATE FUNCTION g() RETURNS text AS $body$ SELECT $$;$$ $body$ LANGUAGE sql", Line: 7},
+				{SQL: "SELECT 1", Line: 8},
+			},
+		},
+		{
+			name:   "parameter is not dollar quote",
+			script: "PREPARE p AS SELECT $1;\nSELECT a$b FROM t;",
+			want:   []Statement{{SQL: "PREPARE p AS SELECT $1", Line: 1}, {SQL: "SELECT a$b FROM t", Line: 2}},
+		},
+		{
+			name:   "comments",
+			script: "-- comment; not a statement\n/* block; /* nested; */ comment */\nSELECT 1; -- trailing;\n-- only comment;\n",
+			want:   []Statement{{SQL: "SELECT 1", Line: 3}},
+		},
+		{
+			name:   "line number after multi-line string",
+			script: "INSERT INTO a VALUES ('a\nb\nc');\nSELECT 1;",
+			want:   []Statement{{SQL: "INSERT INTO a VALUES ('a\nb\nc')", Line: 1}, {SQL: "SELECT 1", Line: 4}},
+		},
+		{name: "empty", script: " \n;;\n", want: nil},
+		{name: "unterminated string", script: "SELECT 1;\nSELECT 'a;", wantErr: true},
+		{name: "unterminated identifier", script: `SELECT "a`, wantErr: true},
+		{name: "unterminated dollar quote", script: "DO $$ BEGIN", wantErr: true},
+		{name: "unterminated block comment", script: "/* /* */ SELECT 1;", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.script)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Split() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Split() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package exceltesting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// jsonMatcher は json、jsonb のカラムの期待値を、文字列ではなくJSONの構造で比較します。
+// キーの順序や空白、数値の表現(1.0 と 1)の違いは無視します。
+//
+// partial が true の場合は、期待値がJSONドキュメントの部分集合であれば一致とみなします。
+// オブジェクトは期待値に記載したキーのみを比較し、配列は同じ長さで各要素を同じく部分集合として比較します。
+type jsonMatcher struct {
+	raw     string
+	doc     any
+	partial bool
+}
+
+// newJSONMatcher は期待値のJSONを解析して jsonMatcher を作成します。
+func newJSONMatcher(raw string, partial bool) (jsonMatcher, error) {
+	doc, err := decodeJSON(raw)
+	if err != nil {
+		return jsonMatcher{}, err
+	}
+	return jsonMatcher{raw: raw, doc: doc, partial: partial}, nil
+}
+
+func (m jsonMatcher) match(v any) bool {
+	var raw string
+	switch v := v.(type) {
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	default:
+		return false
+	}
+	doc, err := decodeJSON(raw)
+	if err != nil {
+		return false
+	}
+	return equalJSON(m.doc, doc, m.partial)
+}
+
+func (m jsonMatcher) String() string {
+	if m.partial {
+		return fmt.Sprintf("<JSON_SUBSET:%s>", m.raw)
+	}
+	return m.raw
+}
+
+// decodeJSON はJSONを数値の精度を保ったまま解析します。
+func decodeJSON(raw string) (any, error) {
+	d := json.NewDecoder(strings.NewReader(raw))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid json: unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+// equalJSON は解析したJSONの値を構造で比較します。partial が true の場合は want が got の部分集合であるかを判定します。
+func equalJSON(want, got any, partial bool) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok || !partial && len(w) != len(g) {
+			return false
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok || !equalJSON(wv, gv, partial) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(w) != len(g) {
+			return false
+		}
+		for i := range w {
+			if !equalJSON(w[i], g[i], partial) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		g, ok := got.(json.Number)
+		if !ok {
+			return false
+		}
+		wr, ok1 := new(big.Rat).SetString(w.String())
+		gr, ok2 := new(big.Rat).SetString(g.String())
+		return ok1 && ok2 && wr.Cmp(gr) == 0
+	default:
+		// string, bool, nil
+		return want == got
+	}
+}
@@ -0,0 +1,37 @@
+package exceltesting
+
+import "testing"
+
+func Test_jsonMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		partial bool
+		got     any
+		equal   bool
+	}{
+		{name: "key order and spaces", want: `{"b":1,"a":[1,2]}`, got: []byte(`{"a": [1, 2], "b": 1}`), equal: true},
+		{name: "number representation", want: `{"a":1.0}`, got: []byte(`{"a": 1}`), equal: true},
+		{name: "domain value", want: `{"a":1}`, got: `{"a": 1}`, equal: true},
+		{name: "different value", want: `{"a":1}`, got: []byte(`{"a": "1"}`), equal: false},
+		{name: "extra key", want: `{"a":1}`, got: []byte(`{"a": 1, "b": 2}`), equal: false},
+		{name: "array order", want: `[1,2]`, got: []byte(`[2, 1]`), equal: false},
+		{name: "null", want: `{"a":1}`, got: nil, equal: false},
+		{name: "invalid actual json", want: `{"a":1}`, got: []byte(`{"a"`), equal: false},
+		{name: "partial extra key", want: `{"a":{"b":1}}`, partial: true, got: []byte(`{"a": {"b": 1, "c": 2}, "d": 3}`), equal: true},
+		{name: "partial array", want: `{"a":[{"b":1}]}`, partial: true, got: []byte(`{"a": [{"b": 1, "c": 2}]}`), equal: true},
+		{name: "partial array length", want: `{"a":[{"b":1}]}`, partial: true, got: []byte(`{"a": [{"b": 1}, {"b": 2}]}`), equal: false},
+		{name: "partial missing key", want: `{"a":1,"b":2}`, partial: true, got: []byte(`{"a": 1}`), equal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newJSONMatcher(tt.want, tt.partial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.match(tt.got); got != tt.equal {
+				t.Errorf("%s.match(%s) = %v, want %v", m, tt.got, got, tt.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package exceltesting
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// checksum はテーブル名、カラム、データ行から投入するデータのチェックサムを計算します。
+func (t *table) checksum() string {
+	h := sha256.New()
+	writeField(h, t.name)
+	writeFields(h, t.columns)
+	for _, row := range t.data {
+		writeFields(h, row)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFields は値の区切りが曖昧にならないよう、値の数と各値の長さを付与して書き込みます。
+func writeFields(h hash.Hash, fields []string) {
+	_ = binary.Write(h, binary.BigEndian, uint64(len(fields)))
+	for _, f := range fields {
+		writeField(h, f)
+	}
+}
+
+func writeField(h hash.Hash, f string) {
+	_ = binary.Write(h, binary.BigEndian, uint64(len(f)))
+	h.Write([]byte(f))
+}
+
+// loadLedger は投入の記録に用いるテーブルとトリガー関数の作成状態です。
+type loadLedger struct {
+	mu sync.Mutex
+	// schema はテーブルとトリガー関数を作成したスキーマです。作成前は空です
+	schema string
+}
+
+// ensureLoadLedger は投入の記録に用いるテーブルとトリガー関数を作成し、作成したスキーマを返します。
+// 作成は exceltesing ごとに1回のみ行います。
+func (e *exceltesing) ensureLoadLedger(ctx context.Context) (string, error) {
+	e.ledger.mu.Lock()
+	defer e.ledger.mu.Unlock()
+	if e.ledger.schema != "" {
+		return e.ledger.schema, nil
+	}
+
+	// 複数のプロセスが同時に作成した場合は、既に存在するとみなす
+	if _, err := e.db.ExecContext(ctx, createLoadLedgerQuery); err != nil && !isAlreadyExists(err) {
+		return "", fmt.Errorf("create %s: %w", loadLedgerTable, err)
+	}
+	var schema string
+	if err := e.db.QueryRowContext(ctx, `SELECT current_schema();`).Scan(&schema); err != nil {
+		return "", fmt.Errorf("get current schema: %w", err)
+	}
+	function := quoteIdent(schema) + "." + loadLedgerFunction
+	var exists bool
+	if err := e.db.QueryRowContext(ctx, `SELECT to_regprocedure($1) IS NOT NULL;`, function+"()").Scan(&exists); err != nil {
+		return "", fmt.Errorf("get %s: %w", loadLedgerFunction, err)
+	}
+	if !exists {
+		if _, err := e.db.ExecContext(ctx, fmt.Sprintf(createLoadLedgerFunctionQuery, function)); err != nil && !isAlreadyExists(err) {
+			return "", fmt.Errorf("create %s: %w", loadLedgerFunction, err)
+		}
+	}
+
+	e.ledger.schema = schema
+	return schema, nil
+}
+
+// ensureLoadLedgerTrigger はテーブルに変更回数を数えるトリガーを作成します。
+// 投入後に他のトランザクションが行の追加、更新、削除、TRUNCATE を行うと、投入の記録の変更回数を加算します。
+func (e *exceltesing) ensureLoadLedgerTrigger(ctx context.Context, schema, tableName string) error {
+	var exists bool
+	if err := e.db.QueryRowContext(ctx, existsLoadLedgerTriggerQuery, tableName).Scan(&exists); err != nil {
+		return fmt.Errorf("get trigger of %s: %w", tableName, err)
+	}
+	if exists {
+		return nil
+	}
+	q := fmt.Sprintf(createLoadLedgerTriggerQuery, tableName,
+		quoteIdent(schema)+"."+loadLedgerFunction,
+		quoteLiteral(quoteIdent(schema)+"."+loadLedgerTable),
+		quoteLiteral(tableName),
+	)
+	if _, err := e.db.ExecContext(ctx, q); err != nil && !isAlreadyExists(err) {
+		return fmt.Errorf("create trigger of %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// isAlreadyExists は他のプロセスが同時に作成したために、作成に失敗したエラーかどうかを返します。
+// CREATE TABLE IF NOT EXISTS も同時に実行するとカタログの一意制約に違反する場合があります。
+func isAlreadyExists(err error) bool {
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.SQLState() {
+	case "23505", "42P07", "42710", "42723": // unique_violation, duplicate_table, duplicate_object, duplicate_function
+		return true
+	}
+	return false
+}
+
+// unchangedSinceLoad は前回の投入時とデータのチェックサムが一致し、
+// 投入後にテーブルが変更されていない場合に true を返します。
+func (e *exceltesing) unchangedSinceLoad(ctx context.Context, tableName, checksum string) (bool, error) {
+	if _, err := e.ensureLoadLedger(ctx); err != nil {
+		return false, err
+	}
+
+	var (
+		recordedChecksum string
+		modified         int64
+		hasTrigger       bool
+	)
+	err := e.db.QueryRowContext(ctx, getLoadLedgerQuery, tableName).Scan(&recordedChecksum, &modified, &hasTrigger)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get %s: %w", loadLedgerTable, err)
+	}
+	return recordedChecksum == checksum && modified == 0 && hasTrigger, nil
+}
+
+// forgetLoad は投入の記録を削除します。投入に失敗した場合に、次回の投入を省略しないよう投入前に削除します。
+func (e *exceltesing) forgetLoad(ctx context.Context, tableName string) error {
+	if _, err := e.db.ExecContext(ctx, deleteLoadLedgerQuery, tableName); err != nil {
+		return fmt.Errorf("delete %s: %w", loadLedgerTable, err)
+	}
+	return nil
+}
+
+// recordLoad はテーブルに変更回数を数えるトリガーを作成し、投入したデータのチェックサムを変更回数 0 として記録します。
+func (e *exceltesing) recordLoad(ctx context.Context, tableName, checksum string) error {
+	schema, err := e.ensureLoadLedger(ctx)
+	if err != nil {
+		return err
+	}
+	if err := e.ensureLoadLedgerTrigger(ctx, schema, tableName); err != nil {
+		return err
+	}
+	if _, err := e.db.ExecContext(ctx, upsertLoadLedgerQuery, tableName, checksum); err != nil {
+		return fmt.Errorf("upsert %s: %w", loadLedgerTable, err)
+	}
+	return nil
+}
+
+// DropLoadLedger は EnableSkipUnchanged で作成した投入の記録のテーブル、トリガー関数と、
+// 投入したテーブルに作成した変更回数を数えるトリガーを削除します。
+//
+// トリガーは投入したテーブルに残り続けるため、pg_dump やスキーマのダンプ、CloneSchema で複製したテーブルにも含まれます。
+// テストデータベースを破棄せずにスキーマを出力する場合などは、事前に DropLoadLedger で削除します。
+// 削除後に EnableSkipUnchanged を指定して投入すると、すべてのシートを投入し直します。
+func (e *exceltesing) DropLoadLedger(ctx context.Context) error {
+	e.ledger.mu.Lock()
+	defer e.ledger.mu.Unlock()
+
+	var schema string
+	if err := e.db.QueryRowContext(ctx, `SELECT current_schema();`).Scan(&schema); err != nil {
+		return fmt.Errorf("exceltesting: get current schema: %w", err)
+	}
+	// トリガーはトリガー関数に依存するため、CASCADE で関数と共に削除する
+	if _, err := e.db.ExecContext(ctx, fmt.Sprintf(dropLoadLedgerFunctionQuery, quoteIdent(schema)+"."+loadLedgerFunction)); err != nil {
+		return fmt.Errorf("exceltesting: drop %s: %w", loadLedgerFunction, err)
+	}
+	if _, err := e.db.ExecContext(ctx, fmt.Sprintf(dropLoadLedgerQuery, quoteIdent(schema)+"."+loadLedgerTable)); err != nil {
+		return fmt.Errorf("exceltesting: drop %s: %w", loadLedgerTable, err)
+	}
+	e.ledger.schema = ""
+	return nil
+}
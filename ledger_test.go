@@ -0,0 +1,89 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/future-architect/go-exceltesting/testonly"
+)
+
+func Test_table_checksum(t *testing.T) {
+	base := &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"1", "A社"}, {"2", ""}}}
+	tests := []struct {
+		name  string
+		table *table
+		equal bool
+	}{
+		{name: "same data", table: &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"1", "A社"}, {"2", ""}}}, equal: true},
+		{name: "different table", table: &table{name: "company2", columns: []string{"id", "name"}, data: [][]string{{"1", "A社"}, {"2", ""}}}},
+		{name: "different value", table: &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"1", "B社"}, {"2", ""}}}},
+		{name: "different row order", table: &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"2", ""}, {"1", "A社"}}}},
+		{name: "moved boundary", table: &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"1A", "社"}, {"2", ""}}}},
+		{name: "rows shifted to columns", table: &table{name: "company", columns: []string{"id", "name"}, data: [][]string{{"1", "A社", "2", ""}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.table.checksum() == base.checksum(); got != tt.equal {
+				t.Errorf("checksum() equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func countLoadLedgerTriggers(t *testing.T, conn *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(`SELECT count(*) FROM pg_trigger WHERE tgname = $1;`, loadLedgerTrigger).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func Test_exceltesing_DropLoadLedger(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+	ctx := context.Background()
+	e := New(conn)
+	if err := e.DropLoadLedger(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// EnableSkipUnchanged を指定しない場合はトリガーを作成しない
+	r := LoadRequest{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-"}
+	e.Load(t, r)
+	if n := countLoadLedgerTriggers(t, conn); n != 0 {
+		t.Errorf("Load() without EnableSkipUnchanged should not leave triggers but %d", n)
+	}
+
+	r.EnableSkipUnchanged = true
+	e.Load(t, r)
+	if n := countLoadLedgerTriggers(t, conn); n == 0 {
+		t.Errorf("Load() with EnableSkipUnchanged should create triggers")
+	}
+
+	// 削除後はトリガーもテーブルも残らず、再び投入できる
+	if err := e.DropLoadLedger(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLoadLedgerTriggers(t, conn); n != 0 {
+		t.Errorf("DropLoadLedger() should drop triggers but %d remain", n)
+	}
+	var exists bool
+	if err := conn.QueryRow(`SELECT to_regclass($1) IS NOT NULL;`, loadLedgerTable).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("DropLoadLedger() should drop %s", loadLedgerTable)
+	}
+	e.Load(t, r)
+	if n := countLoadLedgerTriggers(t, conn); n == 0 {
+		t.Errorf("Load() after DropLoadLedger() should create triggers again")
+	}
+	if err := e.DropLoadLedger(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,271 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// LockScope は Load、Compare で取得するアドバイザリロックの範囲です。
+//
+// go test ./... はパッケージごとに別のプロセスで同じデータベースに対してテストを実行するため、
+// 同じテーブルを投入するテストが同時に実行されるとデータが壊れます。
+// ロックを指定すると PostgreSQL のアドバイザリロックを取得し、テーブルやブックを共有するテストのみ直列に実行します。
+type LockScope string
+
+const (
+	// LockNone はロックを取得しません(デフォルト)
+	LockNone LockScope = ""
+	// LockTable は対象のシートのテーブルごとにロックを取得します
+	LockTable LockScope = "table"
+	// LockBook はブックごとにロックを取得します
+	LockBook LockScope = "book"
+)
+
+// LockTimeout は Load、Compare が他のセッションの保持しているアドバイザリロックを待つ最大の時間です。
+//
+// 別のプロセスのテストが互いに相手の保持しているロックを待つと、どちらも終了しなくなります。
+// 超えた場合は待っているロックのキーを含めて失敗します。
+var LockTimeout = time.Minute
+
+// validate はロックの範囲が正しいか検証します。
+func (s LockScope) validate() error {
+	switch s {
+	case LockNone, LockTable, LockBook:
+		return nil
+	}
+	return fmt.Errorf("unknown lock scope %q", s)
+}
+
+// lockKeys はロックを取得するキーを返します。
+func lockKeys(scope LockScope, wb *workbook, sheets []string) []string {
+	var keys []string
+	switch scope {
+	case LockTable:
+		for _, name := range sheets {
+			// 解析できないシートは Load、Compare でエラーとして報告する
+			if s, err := wb.sheet(name); err == nil {
+				keys = append(keys, "exceltesting:table:"+s.table.name)
+			}
+		}
+	case LockBook:
+		path := wb.src.path
+		if wb.src.onDisk() {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+		}
+		keys = append(keys, "exceltesting:book:"+path)
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// heldLock はテストが取得したアドバイザリロックです。
+type heldLock struct {
+	// owner はロックを取得したテストです。テスト名もしくは Reporter です
+	owner any
+	// refs はロックを取得した Load、Compare の数です。0 になった時に解放します
+	refs int
+	conn *lockedConn
+}
+
+// lockedConn はアドバイザリロックを取得した接続です。
+// アドバイザリロックはセッション単位のため、ロックを全て解放するまで接続を保持します。
+type lockedConn struct {
+	conn  *sql.Conn
+	owned bool
+	// keys は接続で保持しているロックの数です
+	keys int
+}
+
+// heldLocks はプロセス内でテストが取得したアドバイザリロックの一覧です。キーはロックのキーです。
+//
+// 同じテストとそのサブテストは同じロックを共有します。親のテストが保持しているロックを、
+// サブテストが別の接続で取得しようとすると、親のテストの終了まで待ち続けてしまうためです。
+var heldLocks = struct {
+	mu sync.Mutex
+	m  map[string]*heldLock
+}{m: make(map[string]*heldLock)}
+
+// lockOwner はロックを取得したテストを識別する値を返します。
+// サブテストを判定するため、t.Name() がある場合はテスト名を用います。
+func lockOwner(t Reporter) (any, error) {
+	if n, ok := t.(interface{ Name() string }); ok {
+		return n.Name(), nil
+	}
+	if reflect.TypeOf(t).Comparable() {
+		return t, nil
+	}
+	return nil, fmt.Errorf("lock requires a Reporter with Name() or a comparable Reporter but %T", t)
+}
+
+// sharesLock は owner が holder の取得したロックを共有できる場合に true を返します。
+// 同じテストか、holder のサブテストの場合に共有できます。
+func sharesLock(owner, holder any) bool {
+	if owner == holder {
+		return true
+	}
+	o, ok1 := owner.(string)
+	h, ok2 := holder.(string)
+	return ok1 && ok2 && strings.HasPrefix(o, h+"/")
+}
+
+// lockForTest はテストが終了するまでアドバイザリロックを取得します。ロックは t.Cleanup で解放します。
+// 同じテストやサブテストが既に取得しているロックは、取得し直さずに共有します。
+func (e *exceltesing) lockForTest(t Reporter, keys []string) error {
+	t.Helper()
+	if len(keys) == 0 {
+		return nil
+	}
+	owner, err := lockOwner(t)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var c *lockedConn
+	// キーの順にロックを取得し、他のテストとのデッドロックを避ける
+	for _, key := range keys {
+		heldLocks.mu.Lock()
+		h, ok := heldLocks.m[key]
+		if ok && sharesLock(owner, h.owner) {
+			h.refs++
+			heldLocks.mu.Unlock()
+			t.Cleanup(func() { releaseLock(t, key, h) })
+			continue
+		}
+		heldLocks.mu.Unlock()
+
+		if c == nil {
+			conn, owned, err := e.lockConn(ctx)
+			if err != nil {
+				return err
+			}
+			c = &lockedConn{conn: conn, owned: owned}
+		}
+		if err := acquireLock(ctx, c.conn, key); err != nil {
+			heldLocks.mu.Lock()
+			unused := c.keys == 0
+			heldLocks.mu.Unlock()
+			if unused && c.owned {
+				c.conn.Close()
+			}
+			return err
+		}
+
+		h = &heldLock{owner: owner, refs: 1, conn: c}
+		heldLocks.mu.Lock()
+		c.keys++
+		heldLocks.m[key] = h
+		heldLocks.mu.Unlock()
+		t.Cleanup(func() { releaseLock(t, key, h) })
+	}
+	return nil
+}
+
+// releaseLock はロックの参照数を減らし、0 になった場合にロックを解放します。
+func releaseLock(t Reporter, key string, h *heldLock) {
+	heldLocks.mu.Lock()
+	h.refs--
+	if h.refs > 0 {
+		heldLocks.mu.Unlock()
+		return
+	}
+	if heldLocks.m[key] == h {
+		delete(heldLocks.m, key)
+	}
+	c := h.conn
+	c.keys--
+	closeConn := c.keys == 0 && c.owned
+	heldLocks.mu.Unlock()
+
+	if _, err := c.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1));`, key); err != nil {
+		t.Errorf("exceltesting: unlock %s: %v", key, err)
+	}
+	if closeConn {
+		c.conn.Close()
+	}
+}
+
+// lockForCall はアドバイザリロックを取得し、戻り値の関数で解放します。
+// テストの外で利用する LoadWithContext、CompareWithResult で、呼び出しの間のみロックを取得します。
+func (e *exceltesing) lockForCall(ctx context.Context, keys []string) (func(), error) {
+	if len(keys) == 0 {
+		return func() {}, nil
+	}
+	conn, owned, err := e.lockConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked []string
+	unlock := func() {
+		for _, key := range locked {
+			_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1));`, key)
+		}
+		if owned {
+			conn.Close()
+		}
+	}
+	for _, key := range keys {
+		if err := acquireLock(ctx, conn, key); err != nil {
+			unlock()
+			return nil, err
+		}
+		locked = append(locked, key)
+	}
+	return unlock, nil
+}
+
+// acquireLock はアドバイザリロックを取得します。他のセッションが保持している場合は LockTimeout まで取得し直します。
+// 同じテストが複数回に分けて取得したロックを、別のプロセスのテストが逆の順に取得してもデッドロックしないよう、
+// pg_advisory_lock で待ち続けずにタイムアウトで失敗します。
+func acquireLock(ctx context.Context, conn *sql.Conn, key string) error {
+	deadline := time.Now().Add(LockTimeout)
+	wait := 10 * time.Millisecond
+	for {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1));`, key).Scan(&ok); err != nil {
+			return fmt.Errorf("lock %s: %w", key, err)
+		}
+		if ok {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("lock %s: held by another session for more than %s (tests waiting for each other's locks can be avoided by taking all tables in one Load or Compare, or by LockBook)", key, LockTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("lock %s: %w", key, ctx.Err())
+		case <-time.After(wait):
+		}
+		if wait < 200*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// lockConn はロックを取得する接続を返します。
+// New に *sql.Conn を指定した場合はその接続を、*sql.DB を指定した場合は新しい接続を返します。新しい接続の場合は owned が true です。
+func (e *exceltesing) lockConn(ctx context.Context) (conn *sql.Conn, owned bool, err error) {
+	switch db := e.db.(type) {
+	case *sql.Conn:
+		return db, false, nil
+	case interface {
+		Conn(context.Context) (*sql.Conn, error)
+	}:
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, false, fmt.Errorf("get connection: %w", err)
+		}
+		return conn, true, nil
+	}
+	return nil, false, fmt.Errorf("lock requires *sql.DB or *sql.Conn but %T", e.db)
+}
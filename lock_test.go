@@ -0,0 +1,193 @@
+package exceltesting
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/future-architect/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_lockKeys(t *testing.T) {
+	wb, err := openWorkbook(bookSource{path: filepath.Join("testdata", "load.xlsx")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wb.Close()
+
+	abs, err := filepath.Abs(filepath.Join("testdata", "load.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheets := targetSheets(wb.sheetList(), "normal-", nil)
+
+	tests := []struct {
+		name  string
+		scope LockScope
+		want  []string
+	}{
+		{name: "none", scope: LockNone, want: nil},
+		{name: "table", scope: LockTable, want: []string{"exceltesting:table:test_x"}},
+		{name: "book", scope: LockBook, want: []string{"exceltesting:book:" + abs}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lockKeys(tt.scope, wb, sheets)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("lockKeys() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	fsWb, err := openWorkbook(bookSource{path: "load.xlsx", fsys: os.DirFS("testdata")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsWb.Close()
+	if diff := cmp.Diff([]string{"exceltesting:book:load.xlsx"}, lockKeys(LockBook, fsWb, nil)); diff != "" {
+		t.Errorf("lockKeys() of fs.FS book mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_exceltesing_Load_lock(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// 別のセッションからロックを取得できるかどうかで、ロックが保持されているか判定する
+	locked := func() bool {
+		t.Helper()
+		c, err := conn.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		var ok bool
+		if err := c.QueryRowContext(context.Background(), `SELECT pg_try_advisory_lock(hashtext('exceltesting:table:test_x'));`).Scan(&ok); err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if _, err := c.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext('exceltesting:table:test_x'));`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return !ok
+	}
+
+	t.Run("load", func(t *testing.T) {
+		e := New(conn)
+		r := LoadRequest{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-", Lock: LockTable}
+		e.Load(t, r)
+		// 同じテストで再度ロックを取得してもデッドロックしない
+		e.Load(t, r)
+
+		// 親のテストが保持しているロックをサブテストが取得してもデッドロックしない
+		t.Run("subtest", func(t *testing.T) {
+			e.Load(t, r)
+		})
+
+		if !locked() {
+			t.Errorf("table lock should be held until the end of the test")
+		}
+	})
+
+	if locked() {
+		t.Errorf("table lock should be released by t.Cleanup")
+	}
+}
+
+// cleanupReporter は Cleanup に登録した関数を cleanup で実行する Reporter です
+type cleanupReporter struct {
+	fakeReporter
+	cleanups []func()
+}
+
+func (r *cleanupReporter) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+
+func (r *cleanupReporter) cleanup() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func Test_exceltesing_lockForTest_oppositeOrder(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	defer conn.Close()
+
+	defer func(d time.Duration) { LockTimeout = d }(LockTimeout)
+	LockTimeout = 200 * time.Millisecond
+
+	// 別のプロセスのテストが互いに相手の保持しているキーを取得しようとしても、待ち続けずに失敗する
+	e := New(conn)
+	a, b := &cleanupReporter{}, &cleanupReporter{}
+	defer a.cleanup()
+	defer b.cleanup()
+	if err := e.lockForTest(a, []string{"exceltesting:test:company"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.lockForTest(b, []string{"exceltesting:test:employee"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = e.lockForTest(a, []string{"exceltesting:test:employee"})
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = e.lockForTest(b, []string{"exceltesting:test:company"})
+	}()
+	wg.Wait()
+
+	for i, key := range []string{"exceltesting:test:employee", "exceltesting:test:company"} {
+		if errs[i] == nil || !strings.Contains(errs[i].Error(), "lock "+key+": held by another session") {
+			t.Errorf("lockForTest() should fail naming %s but %v", key, errs[i])
+		}
+	}
+}
+
+// nonComparableReporter は map のキーにできない Reporter です
+type nonComparableReporter struct {
+	Reporter
+	logs []string
+}
+
+func Test_lockOwner(t *testing.T) {
+	if got, err := lockOwner(t); err != nil || got != t.Name() {
+		t.Errorf("lockOwner() = %v, %v, want %s", got, err, t.Name())
+	}
+	if _, err := lockOwner(nonComparableReporter{Reporter: t}); err == nil {
+		t.Errorf("lockOwner() should return error for a non-comparable Reporter without Name()")
+	}
+}
+
+func Test_sharesLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		owner  any
+		holder any
+		want   bool
+	}{
+		{name: "same test", owner: "TestA", holder: "TestA", want: true},
+		{name: "subtest", owner: "TestA/sub/sub", holder: "TestA", want: true},
+		{name: "parent", owner: "TestA", holder: "TestA/sub", want: false},
+		{name: "other test with the same prefix", owner: "TestAB", holder: "TestA", want: false},
+		{name: "different types", owner: "TestA", holder: 1, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sharesLock(tt.owner, tt.holder); got != tt.want {
+				t.Errorf("sharesLock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
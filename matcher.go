@@ -0,0 +1,213 @@
+package exceltesting
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// matcher は期待値のセルに記載する、値が条件を満たすかを判定するための記法です。
+//
+// 以下の記法をサポートしています。
+//
+//	<ANY>                 任意の値(NULLを含む)
+//	<NOT NULL>            NULL以外の値
+//	<NULL>                NULL
+//	<REGEX:^INV-\d+$>     文字列表現が正規表現に一致する値
+//	<NOW±5s>              現在時刻との差が指定した時間以内の日時。<NOW+-5s> とも記載できます
+//	<APPROX:3.14,0.01>    期待値との差が許容誤差以内の数値
+//	<UUID>                UUID形式の値
+//	<JSON_SUBSET:{"a":1}> 記載したJSONを部分集合として含むJSON
+type matcher interface {
+	match(v any) bool
+	String() string
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parseMatcher はセルの値を matcher として解析します。
+// セルが matcher の記法でない場合は nil を返します。
+func parseMatcher(cell string) (matcher, error) {
+	s := strings.TrimSpace(cell)
+	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") {
+		return nil, nil
+	}
+	body := s[1 : len(s)-1]
+	name, arg, hasArg := strings.Cut(body, ":")
+
+	switch {
+	case body == "ANY":
+		return anyMatcher{}, nil
+	case body == "NOT NULL":
+		return notNullMatcher{}, nil
+	case body == "NULL":
+		return nullMatcher{}, nil
+	case body == "UUID":
+		return uuidMatcher{}, nil
+	case hasArg && name == "REGEX":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %s: %w", s, err)
+		}
+		return regexMatcher{re: re}, nil
+	case hasArg && name == "APPROX":
+		want, delta, ok := strings.Cut(arg, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid matcher %s: tolerance is required", s)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(want), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %s: %w", s, err)
+		}
+		d, err := strconv.ParseFloat(strings.TrimSpace(delta), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %s: %w", s, err)
+		}
+		return approxMatcher{want: w, delta: math.Abs(d)}, nil
+	case strings.HasPrefix(body, "NOW±"), strings.HasPrefix(body, "NOW+-"):
+		tolerance := strings.TrimPrefix(strings.TrimPrefix(body, "NOW±"), "NOW+-")
+		d, err := time.ParseDuration(tolerance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %s: %w", s, err)
+		}
+		if d < 0 {
+			d = -d
+		}
+		return nowMatcher{tolerance: d}, nil
+	case hasArg && name == "JSON_SUBSET":
+		m, err := newJSONMatcher(arg, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %s: %w", s, err)
+		}
+		return m, nil
+	}
+
+	// matcher の記法でない値(たとえば <b> のような文字列)はそのまま期待値として扱う
+	return nil, nil
+}
+
+type anyMatcher struct{}
+
+func (anyMatcher) match(any) bool { return true }
+func (anyMatcher) String() string { return "<ANY>" }
+
+type notNullMatcher struct{}
+
+func (notNullMatcher) match(v any) bool { return v != nil }
+func (notNullMatcher) String() string   { return "<NOT NULL>" }
+
+type nullMatcher struct{}
+
+func (nullMatcher) match(v any) bool { return v == nil }
+func (nullMatcher) String() string   { return "<NULL>" }
+
+type uuidMatcher struct{}
+
+func (uuidMatcher) match(v any) bool { return v != nil && uuidPattern.MatchString(formatValue(v)) }
+func (uuidMatcher) String() string   { return "<UUID>" }
+
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexMatcher) match(v any) bool { return v != nil && m.re.MatchString(formatValue(v)) }
+func (m regexMatcher) String() string   { return fmt.Sprintf("<REGEX:%s>", m.re) }
+
+type approxMatcher struct {
+	want  float64
+	delta float64
+}
+
+func (m approxMatcher) match(v any) bool {
+	if v == nil {
+		return false
+	}
+	got, err := strconv.ParseFloat(formatValue(v), 64)
+	if err != nil {
+		return false
+	}
+	return math.Abs(got-m.want) <= m.delta
+}
+
+func (m approxMatcher) String() string {
+	return fmt.Sprintf("<APPROX:%v,%v>", m.want, m.delta)
+}
+
+type nowMatcher struct {
+	tolerance time.Duration
+	// loc はタイムゾーンのない timestamp の値を解釈するタイムゾーンです。nil の場合は値をそのまま比較します
+	loc *time.Location
+}
+
+// inZone はタイムゾーンのない timestamp のカラムを比較するため、値を loc の日時として解釈する nowMatcher を返します。
+// ドライバは timestamp の値をセッションのタイムゾーンの日時のまま UTC として返します。
+func (m nowMatcher) inZone(loc *time.Location) nowMatcher {
+	m.loc = loc
+	return m
+}
+
+func (m nowMatcher) match(v any) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	if m.loc != nil {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), m.loc)
+	}
+	d := time.Since(t)
+	if d < 0 {
+		d = -d
+	}
+	return d <= m.tolerance
+}
+
+func (m nowMatcher) String() string { return fmt.Sprintf("<NOW±%s>", m.tolerance) }
+
+// extractMatchers は期待値から matcher の記法で記載されたセルを取り出します。
+// 戻り値の table は matcher のセルを空(NULL)に置き換え、その他のセルを n に従って正規化したコピーで、
+// 戻り値の [][]matcher は t.data と同じ行、列の位置に matcher を保持します。
+// <RAW:...> の記法で記載したセルは matcher として扱いません。
+func extractMatchers(t *table, n CellNormalization) (table, [][]matcher, error) {
+	c := t.DeepCopy()
+	matchers := make([][]matcher, len(c.data))
+	for i, row := range c.data {
+		for j, cell := range row {
+			if _, ok := rawCell(cell); ok {
+				c.data[i][j] = normalizeCell(cell, n)
+				continue
+			}
+			m, err := parseMatcher(cell)
+			if err != nil {
+				return table{}, nil, fmt.Errorf("column %s: %w", c.columns[j], err)
+			}
+			if m == nil {
+				c.data[i][j] = normalizeCell(cell, n)
+				continue
+			}
+			if matchers[i] == nil {
+				matchers[i] = make([]matcher, len(row))
+			}
+			matchers[i][j] = m
+			c.data[i][j] = ""
+		}
+	}
+	return c, matchers, nil
+}
+
+// matcherColumns は matcher が記載されているカラム名を返します。
+func matcherColumns(t *table, matchers [][]matcher) []string {
+	var columns []string
+	for _, row := range matchers {
+		for j, m := range row {
+			if m != nil && !slices.Contains(columns, t.columns[j]) {
+				columns = append(columns, t.columns[j])
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,86 @@
+package exceltesting
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseMatcher(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		cell      string
+		value     any
+		want      bool
+		isMatcher bool
+		wantErr   bool
+	}{
+		{name: "not matcher", cell: "abc", isMatcher: false},
+		{name: "unknown matcher is literal", cell: "<b>", isMatcher: false},
+		{name: "any", cell: "<ANY>", value: nil, want: true, isMatcher: true},
+		{name: "not null", cell: "<NOT NULL>", value: int64(1), want: true, isMatcher: true},
+		{name: "not null with null", cell: "<NOT NULL>", value: nil, want: false, isMatcher: true},
+		{name: "null", cell: "<NULL>", value: nil, want: true, isMatcher: true},
+		{name: "null with value", cell: "<NULL>", value: "", want: false, isMatcher: true},
+		{name: "regex", cell: `<REGEX:^INV-\d+$>`, value: "INV-0001", want: true, isMatcher: true},
+		{name: "regex unmatched", cell: `<REGEX:^INV-\d+$>`, value: "INV-A", want: false, isMatcher: true},
+		{name: "invalid regex", cell: `<REGEX:(>`, wantErr: true},
+		{name: "now", cell: "<NOW±5s>", value: now.Add(-3 * time.Second), want: true, isMatcher: true},
+		{name: "now with ascii", cell: "<NOW+-5s>", value: now.Add(3 * time.Second), want: true, isMatcher: true},
+		{name: "now out of tolerance", cell: "<NOW±5s>", value: now.Add(-time.Minute), want: false, isMatcher: true},
+		{name: "now with not time", cell: "<NOW±5s>", value: "2022-01-01", want: false, isMatcher: true},
+		{name: "invalid now", cell: "<NOW±5>", wantErr: true},
+		{name: "approx float", cell: "<APPROX:3.14,0.01>", value: 3.141592, want: true, isMatcher: true},
+		{name: "approx numeric", cell: "<APPROX:3.14,0.01>", value: "3.16", want: false, isMatcher: true},
+		{name: "approx int", cell: "<APPROX:3,0.5>", value: int64(3), want: true, isMatcher: true},
+		{name: "invalid approx", cell: "<APPROX:3.14>", wantErr: true},
+		{name: "uuid", cell: "<UUID>", value: "cee0db76-d69c-4ae3-ae33-5b5970adde48", want: true, isMatcher: true},
+		{name: "uuid unmatched", cell: "<UUID>", value: "cee0db76", want: false, isMatcher: true},
+		{name: "json subset", cell: `<JSON_SUBSET:{"a":{"b":1}}>`, value: []byte(`{"a": {"b": 1.0, "c": 2}, "d": [1]}`), want: true, isMatcher: true},
+		{name: "json subset unmatched", cell: `<JSON_SUBSET:{"a":{"b":1}}>`, value: []byte(`{"a": {"c": 2}}`), want: false, isMatcher: true},
+		{name: "invalid json subset", cell: `<JSON_SUBSET:{"a":>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMatcher(tt.cell)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (m != nil) != tt.isMatcher {
+				t.Fatalf("parseMatcher() = %v, isMatcher %v", m, tt.isMatcher)
+			}
+			if m == nil {
+				return
+			}
+			if got := m.match(tt.value); got != tt.want {
+				t.Errorf("%s.match(%v) = %v, want %v", m, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nowMatcher_inZone(t *testing.T) {
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	// ドライバは timestamp の値をセッションのタイムゾーンの日時のまま UTC として返す
+	wall := time.Now().In(tokyo)
+	naive := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+
+	tests := []struct {
+		name string
+		m    nowMatcher
+		v    any
+		want bool
+	}{
+		{name: "timestamp in session zone", m: nowMatcher{tolerance: 5 * time.Second}.inZone(tokyo), v: naive, want: true},
+		{name: "timestamp 9 hours ago in session zone", m: nowMatcher{tolerance: 5 * time.Second}.inZone(tokyo), v: naive.Add(-9 * time.Hour), want: false},
+		{name: "timestamp without zone", m: nowMatcher{tolerance: 5 * time.Second}, v: naive, want: false},
+		{name: "timestamptz", m: nowMatcher{tolerance: 5 * time.Second}, v: time.Now(), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.match(tt.v); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package exceltesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// columnType はカタログから取得したカラムの型です。
+type columnType struct {
+	// 型名(pg_type.typname)。ドメインの場合は基底型の型名です
+	name string
+	// 配列の要素の型名。配列でない場合は空です
+	elem string
+	// 型修飾子。numeric の精度や varchar、bpchar の長さなどで、指定がない場合は -1 です
+	typmod int
+	// ドメインの場合は true です
+	domain bool
+	// 型修飾子を含めた型の名前(format_type)です。期待値をデータベースでカラムの型に変換する際に用います
+	format string
+}
+
+// errUnsupportedType は Go で変換しない型であることを表します。
+// このような型の期待値は castValues でデータベースでカラムの型に変換します。
+var errUnsupportedType = errors.New("unsupported type")
+
+// columnTypes はテーブルのカラムの型と、セッションのタイムゾーンを取得します。
+func (e *exceltesing) columnTypes(tableName string) (map[string]columnType, *time.Location, error) {
+	rows, err := e.db.QueryContext(context.TODO(), getColumnTypesQuery, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]columnType)
+	loc := time.UTC
+	for rows.Next() {
+		var (
+			column, timeZone string
+			ct               columnType
+		)
+		if err := rows.Scan(&column, &ct.name, &ct.elem, &ct.typmod, &ct.domain, &ct.format, &timeZone); err != nil {
+			return nil, nil, err
+		}
+		types[column] = ct
+		if l, err := time.LoadLocation(timeZone); err == nil {
+			loc = l
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return types, loc, nil
+}
+
+// normalizeValue はExcelのセルの値を、データベースから同じ値を取得した場合にドライバが返す値に変換します。
+//
+// Go で変換するのは、入力の解釈が単純でドライバが値をそのまま返す bool、整数、文字列の型のみです。
+// json、jsonb はキーの順序や空白の違いを無視するため、JSONの構造で比較する matcher に変換します。
+// 空のセルは NULL として nil を返します。
+// その他の型や Go で解釈できない値は errUnsupportedType を返し、castValues でデータベースで変換します。
+// 日時や numeric などの出力の表現を Go で再現して、誤って一致とみなさないためです。
+func normalizeValue(cell string, ct columnType) (any, error) {
+	if cell == "" {
+		return nil, nil
+	}
+	if ct.domain || ct.elem != "" {
+		return nil, errUnsupportedType
+	}
+
+	s := strings.TrimSpace(cell)
+	switch ct.name {
+	case "json", "jsonb":
+		m, err := newJSONMatcher(cell, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input %q for type %s: %w", cell, ct.name, err)
+		}
+		return m, nil
+	case "text", "varchar", "name", "citext":
+		return cell, nil
+	case "bool":
+		if v, ok := parseBool(s); ok {
+			return v, nil
+		}
+	case "int2", "int4", "int8":
+		bitSize := map[string]int{"int2": 16, "int4": 32, "int8": 64}[ct.name]
+		if v, err := strconv.ParseInt(s, 10, bitSize); err == nil {
+			return v, nil
+		}
+	case "oid":
+		if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+			return int64(v), nil
+		}
+	}
+	return nil, errUnsupportedType
+}
+
+// parseBool は bool の入力として一般的な表記を解釈します。その他の表記はデータベースで解釈します。
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, true
+	case "f", "false", "n", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// castValues は期待値のセルをデータベースでカラムの型に変換し、セルごとにドライバが返す値を返します。
+// 実際の値と同じくドライバで値を取得するため、値の表現はデータベースの出力とドライバの変換に従います。
+func (e *exceltesing) castValues(cells []string, ct columnType) (map[string]any, error) {
+	if ct.format == "" {
+		return nil, fmt.Errorf("unsupported type %q", ct.name)
+	}
+	cells = slices.Clone(cells)
+	slices.Sort(cells)
+	cells = slices.Compact(cells)
+
+	q := fmt.Sprintf(`SELECT U.v, CAST(U.v AS %s) FROM unnest(CAST($1 AS text[])) AS U(v);`, ct.format)
+	values, err := e.queryCastValues(q, cells)
+	if err == nil {
+		return values, nil
+	}
+	// 変換できないセルをエラーに含めるため、セルごとに変換し直す
+	for _, cell := range cells {
+		if _, err := e.queryCastValues(q, []string{cell}); err != nil {
+			return nil, fmt.Errorf("invalid input %q for type %s: %w", cell, ct.format, err)
+		}
+	}
+	return nil, fmt.Errorf("cast to %s: %w", ct.format, err)
+}
+
+func (e *exceltesing) queryCastValues(q string, cells []string) (map[string]any, error) {
+	rows, err := e.db.QueryContext(context.TODO(), q, cells)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]any, len(cells))
+	for rows.Next() {
+		var (
+			cell string
+			v    any
+		)
+		if err := rows.Scan(&cell, &v); err != nil {
+			return nil, err
+		}
+		values[cell] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,126 @@
+package exceltesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/future-architect/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_normalizeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		cell    string
+		ct      columnType
+		want    any
+		wantErr bool
+	}{
+		{name: "empty is null", cell: "", ct: columnType{name: "int4", typmod: -1}, want: nil},
+		{name: "text", cell: " abc ", ct: columnType{name: "text", typmod: -1}, want: " abc "},
+		{name: "varchar", cell: "001", ct: columnType{name: "varchar", typmod: 5 + 4}, want: "001"},
+		{name: "bool", cell: "TRUE", ct: columnType{name: "bool", typmod: -1}, want: true},
+		{name: "bool off", cell: "off", ct: columnType{name: "bool", typmod: -1}, want: false},
+		{name: "int4", cell: "+42", ct: columnType{name: "int4", typmod: -1}, want: int64(42)},
+		{name: "int8", cell: " -9000000000 ", ct: columnType{name: "int8", typmod: -1}, want: int64(-9000000000)},
+		{name: "oid", cell: "4294967295", ct: columnType{name: "oid", typmod: -1}, want: int64(4294967295)},
+		{name: "invalid json", cell: `{"b":`, ct: columnType{name: "json", typmod: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeValue(tt.cell, tt.ct)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("normalizeValue() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_normalizeValue_unsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		cell string
+		ct   columnType
+	}{
+		{name: "int2 out of range", cell: "40000", ct: columnType{name: "int2", typmod: -1}},
+		{name: "bool spelling", cell: "of", ct: columnType{name: "bool", typmod: -1}},
+		{name: "numeric", cell: "1.5", ct: columnType{name: "numeric", typmod: (10<<16 | 2) + 4}},
+		{name: "float8", cell: "1e3", ct: columnType{name: "float8", typmod: -1}},
+		{name: "timestamptz", cell: "2022-01-02 10:20", ct: columnType{name: "timestamptz", typmod: -1}},
+		{name: "bytea", cell: `\x0aff`, ct: columnType{name: "bytea", typmod: -1}},
+		{name: "bpchar", cell: "001", ct: columnType{name: "bpchar", typmod: 5 + 4}},
+		{name: "interval", cell: "1 day", ct: columnType{name: "interval", typmod: -1}},
+		{name: "bool domain", cell: "yes", ct: columnType{name: "bool", typmod: -1, domain: true}},
+		{name: "jsonb domain", cell: `{"a":1}`, ct: columnType{name: "jsonb", typmod: -1, domain: true}},
+		{name: "int array", cell: "{1,2}", ct: columnType{name: "_int4", elem: "int4", typmod: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := normalizeValue(tt.cell, tt.ct)
+			if !errors.Is(err, errUnsupportedType) {
+				t.Errorf("normalizeValue() error = %v, want %v", err, errUnsupportedType)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_Compare_serverCast(t *testing.T) {
+	db := testonly.OpenTestDB(t)
+	defer db.Close()
+
+	// Go で変換しない型の期待値は、データベースの出力と同じ値になることを実際のサーバーで確認する
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SET TIME ZONE 'Europe/Berlin';`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		typ    string
+		actual string
+		cell   string
+		equal  bool
+	}{
+		{name: "numeric with trailing zeros", typ: "numeric(10,2)", actual: "1.50", cell: "1.5", equal: true},
+		{name: "numeric rounding", typ: "numeric(10,2)", actual: "1.01", cell: "1.005", equal: true},
+		{name: "numeric different value", typ: "numeric(10,2)", actual: "1.50", cell: "1.51", equal: false},
+		{name: "timestamptz before DST", typ: "timestamptz", actual: "'2022-03-27 00:30:00+00'", cell: "2022-03-27 01:30", equal: true},
+		{name: "timestamptz after DST", typ: "timestamptz", actual: "'2022-03-27 01:30:00+00'", cell: "2022-03-27 03:30", equal: true},
+		{name: "timestamptz with offset across DST", typ: "timestamptz", actual: "'2022-10-30 01:30:00+00'", cell: "2022-10-30 02:30:00+01", equal: true},
+		{name: "timestamptz off by DST", typ: "timestamptz", actual: "'2022-10-30 01:30:00+00'", cell: "2022-10-30 02:30:00+02", equal: false},
+		{name: "nested array with NULL", typ: "int4[]", actual: "'{{1,NULL},{3,4}}'", cell: "{{ 1 , null },{3,+4}}", equal: true},
+		{name: "text array with quoting", typ: "text[]", actual: `ARRAY['a b', '"q"', NULL, 'NULL']`, cell: `{"a b","\"q\"",NULL,"NULL"}`, equal: true},
+		{name: "text array NULL is not string", typ: "text[]", actual: `ARRAY['a b', '"q"', NULL, 'NULL']`, cell: `{"a b","\"q\"",NULL,NULL}`, equal: false},
+		{name: "bytea escape format", typ: "bytea", actual: `'\x615c6201'`, cell: `a\\b\001`, equal: true},
+		{name: "bytea hex format", typ: "bytea", actual: `'\x615c6201'`, cell: `\x615C6201`, equal: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := fmt.Sprintf("cast_x%d", i)
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %[1]s; CREATE TABLE %[1]s (id int PRIMARY KEY, v %[2]s); INSERT INTO %[1]s VALUES (1, %[3]s);`, table, tt.typ, tt.actual)); err != nil {
+				t.Fatal(err)
+			}
+			book := filepath.Join(t.TempDir(), "cast.xlsx")
+			writeTestBook(t, book, []testSheet{{name: table, table: table, cols: []string{"id", "v"}, data: [][]string{{"1", "1", tt.cell}}}})
+
+			equal, errs := New(conn).CompareWithContext(ctx, CompareRequest{TargetBookPath: book})
+			if equal != tt.equal {
+				t.Errorf("CompareWithContext() = %v, want %v: %v", equal, tt.equal, errs)
+			}
+		})
+	}
+}
@@ -1,32 +1,24 @@
 package exceltesting
 
 const (
+	// getPrimaryKeyQuery は主キーのカラム名をカンマ区切りで取得します。
+	// パーティションの場合はパーティションテーブル(親テーブル)の主キーを取得します。
 	getPrimaryKeyQuery = `
 SELECT
-	array_to_string(ARRAY_AGG(A.attname), ',')	AS	column_names
+	array_to_string(ARRAY_AGG(A.attname ORDER BY array_position(ix.indkey::int2[], A.attnum)), ',')	AS	column_names
 FROM
-	pg_class		AS	T
-,	pg_class		AS	i
-,	pg_index		AS	ix
+	pg_index		AS	ix
+,	pg_class		AS	T
 ,	pg_attribute	AS	A
-,	pg_tables		AS	ta
 WHERE
-	T.oid			=	ix.indrelid
-AND	i.oid			=	ix.indexrelid
+	T.oid			=	COALESCE(pg_partition_root(to_regclass($1)), to_regclass($1))
+AND	T.relkind		IN	('r', 'p')
+AND	ix.indrelid		=	T.oid
 AND	ix.indisprimary	=	TRUE
 AND	A.attrelid		=	T.oid
 AND	A.attnum		=	ANY(ix.indkey)
-AND	T.relkind		IN	('r', 'p') -- TODO: 将来的には他の relkind にも対応する予定
-AND	T.relname		=	ta.tablename
-AND	ta.schemaname	=	CURRENT_SCHEMA()
-AND	T.relname		=	$1
 GROUP BY
-	T.relname
-,	i.relname
-,	ix.indisprimary
-ORDER BY
-	T.relname
-,	i.relname
+	ix.indexrelid
 ;`
 
 	getTableNotNullColumns = `
@@ -48,4 +40,159 @@ ORDER BY
 	ordinal_position
 ;
 `
+
+	// getColumnTypesQuery はカラムの型と、セッションのタイムゾーンを取得します。
+	// ドメインのカラムは基底型を取得します。型修飾子を含めた型の名前は、カラムの型(ドメインの場合はドメイン)です。
+	getColumnTypesQuery = `
+SELECT
+	A.attname																	AS	column_name
+,	T.typname																	AS	type_name
+,	COALESCE(E.typname, '')														AS	elem_type_name
+,	CASE WHEN D.typtype = 'd' THEN D.typtypmod ELSE A.atttypmod END				AS	type_mod
+,	D.typtype = 'd'																AS	is_domain
+,	format_type(A.atttypid, A.atttypmod)										AS	type_format
+,	current_setting('TimeZone')													AS	time_zone
+FROM
+	pg_attribute	AS	A
+	INNER JOIN pg_type	AS	D
+		ON	D.oid	=	A.atttypid
+	INNER JOIN pg_type	AS	T
+		ON	T.oid	=	CASE WHEN D.typtype = 'd' THEN D.typbasetype ELSE D.oid END
+	LEFT OUTER JOIN pg_type	AS	E
+		ON	E.oid			=	T.typelem
+		AND	T.typcategory	=	'A'
+WHERE
+	A.attrelid		=	to_regclass($1)
+AND	A.attnum		>	0
+AND	NOT A.attisdropped
+ORDER BY
+	A.attnum
+;`
+)
+
+const (
+	// loadLedgerTable は Load で投入したシートのデータのチェックサムと、投入後のテーブルの変更回数を記録するテーブルです。
+	loadLedgerTable = "exceltesting_load_ledger"
+	// loadLedgerFunction は投入後のテーブルの変更回数を数えるトリガー関数です。
+	loadLedgerFunction = "exceltesting_load_ledger_modified"
+	// loadLedgerTrigger は投入したテーブルに作成する、変更回数を数えるトリガーです。
+	loadLedgerTrigger = "exceltesting_load_ledger"
+
+	createLoadLedgerQuery = `
+CREATE TABLE IF NOT EXISTS ` + loadLedgerTable + ` (
+	table_name	text		PRIMARY KEY
+,	checksum	text		NOT NULL
+,	modified	bigint		NOT NULL	DEFAULT 0
+,	loaded_at	timestamptz	NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);`
+
+	// createLoadLedgerFunctionQuery はトリガーの引数に指定した投入の記録のテーブル、テーブル名の変更回数を加算する関数を作成します。
+	// 文単位のトリガーのため、行を変更しない文も1回と数えます。投入の記録のテーブルを削除した場合は何もしません。
+	createLoadLedgerFunctionQuery = `
+CREATE FUNCTION %s() RETURNS trigger AS $$
+BEGIN
+	IF to_regclass(TG_ARGV[0]) IS NOT NULL THEN
+		EXECUTE format('UPDATE %%s SET modified = modified + 1 WHERE table_name = $1', TG_ARGV[0]) USING TG_ARGV[1];
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`
+
+	createLoadLedgerTriggerQuery = `
+CREATE TRIGGER ` + loadLedgerTrigger + `
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %s
+FOR EACH STATEMENT EXECUTE PROCEDURE %s(%s, %s);`
+
+	existsLoadLedgerTriggerQuery = `
+SELECT EXISTS (
+	SELECT 1 FROM pg_trigger WHERE tgrelid = to_regclass($1) AND tgname = '` + loadLedgerTrigger + `'
+);`
+
+	// getLoadLedgerQuery は投入の記録と、テーブルに変更回数を数えるトリガーが存在するかどうかを取得します。
+	// テーブルを作成し直した場合はトリガーが存在しないため、変更を検出できません。
+	getLoadLedgerQuery = `
+SELECT
+	L.checksum
+,	L.modified
+,	EXISTS (
+		SELECT 1 FROM pg_trigger AS T WHERE T.tgrelid = to_regclass(L.table_name) AND T.tgname = '` + loadLedgerTrigger + `'
+	)	AS	has_trigger
+FROM
+	` + loadLedgerTable + `	AS	L
+WHERE
+	L.table_name	=	$1
+;`
+
+	upsertLoadLedgerQuery = `
+INSERT INTO ` + loadLedgerTable + ` (table_name, checksum, modified, loaded_at)
+VALUES ($1, $2, 0, CURRENT_TIMESTAMP)
+ON CONFLICT (table_name) DO UPDATE SET
+	checksum	=	EXCLUDED.checksum
+,	modified	=	0
+,	loaded_at	=	EXCLUDED.loaded_at
+;`
+
+	deleteLoadLedgerQuery = `
+DELETE FROM ` + loadLedgerTable + ` WHERE table_name = $1;`
+
+	// dropLoadLedgerFunctionQuery はトリガー関数と、関数を実行するすべてのテーブルのトリガーを削除します。
+	dropLoadLedgerFunctionQuery = `
+DROP FUNCTION IF EXISTS %s() CASCADE;`
+
+	dropLoadLedgerQuery = `
+DROP TABLE IF EXISTS %s;`
+)
+
+const (
+	// getCloneTablesQuery は複製するスキーマのテーブルを取得します。
+	// パーティションは親テーブルの後に作成するよう、階層の浅い順に並べます。
+	getCloneTablesQuery = `
+SELECT
+	C.relname
+,	C.relkind
+,	COALESCE(pg_get_partkeydef(C.oid), '')				AS	partition_key
+,	COALESCE(P.relname, '')								AS	parent
+,	COALESCE(pg_get_expr(C.relpartbound, C.oid), '')	AS	partition_bound
+FROM
+	pg_class		AS	C
+	INNER JOIN	pg_namespace	AS	N
+		ON	N.oid	=	C.relnamespace
+	LEFT OUTER JOIN	pg_inherits	AS	I
+		ON	I.inhrelid	=	C.oid
+		AND	C.relispartition
+	LEFT OUTER JOIN	pg_class	AS	P
+		ON	P.oid	=	I.inhparent
+WHERE
+	N.nspname	=	$1
+AND	C.relkind	IN	('r', 'p')
+ORDER BY
+	(SELECT count(*) FROM pg_partition_ancestors(C.oid))
+,	C.relname
+;`
+
+	// getCloneSequencesQuery は複製するスキーマの serial 型のカラムが所有するシーケンスを取得します。
+	// identity 列のシーケンスは LIKE ... INCLUDING IDENTITY で複製されるため対象外です。
+	getCloneSequencesQuery = `
+SELECT
+	T.relname	AS	table_name
+,	A.attname	AS	column_name
+,	S.relname	AS	sequence_name
+FROM
+	pg_depend		AS	D
+	INNER JOIN	pg_class		AS	S
+		ON	S.oid		=	D.objid
+		AND	S.relkind	=	'S'
+	INNER JOIN	pg_class		AS	T
+		ON	T.oid		=	D.refobjid
+	INNER JOIN	pg_attribute	AS	A
+		ON	A.attrelid	=	T.oid
+		AND	A.attnum	=	D.refobjsubid
+	INNER JOIN	pg_namespace	AS	N
+		ON	N.oid		=	T.relnamespace
+WHERE
+	N.nspname	=	$1
+AND	D.deptype	=	'a'
+ORDER BY
+	S.relname
+;`
 )
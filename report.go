@@ -0,0 +1,196 @@
+package exceltesting
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+// reportTemplate は比較結果のHTMLレポートのテンプレートです。
+// CIの成果物として単体で閲覧できるよう、スタイルを含めて1ファイルで出力します。
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>exceltesting: {{ .Title }}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 24px; }
+h1 { font-size: 20px; }
+h2 { font-size: 16px; margin-top: 32px; }
+table { border-collapse: collapse; margin-top: 8px; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; white-space: pre; }
+th { background: #f3f3f3; }
+th.logical { font-weight: normal; }
+th.group { text-align: center; }
+td.key { color: #666; }
+td.null { color: #999; font-style: italic; }
+td.separator, th.separator { border-top: none; border-bottom: none; padding: 0 4px; background: none; }
+td.changed { background: #ffc7ce; }
+tr.missing td.want { background: #ffeb9c; }
+tr.unexpected td.got { background: #bdd7ee; }
+.status { display: inline-block; padding: 0 8px; border-radius: 4px; color: #fff; }
+.pass { background: #2e7d32; }
+.fail { background: #c62828; }
+.error { color: #c62828; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<p>{{ .Passed }} passed, {{ .Failed }} failed</p>
+<ul>
+{{- range .Tables }}
+<li><a href="#{{ .ID }}">{{ .Sheet }}</a> <span class="status {{ if .Pass }}pass">PASS{{ else }}fail">FAIL{{ end }}</span></li>
+{{- end }}
+</ul>
+{{- range .Tables }}
+<h2 id="{{ .ID }}">{{ .Sheet }} ({{ .Table }}) <span class="status {{ if .Pass }}pass">PASS{{ else }}fail">FAIL{{ end }}</span></h2>
+{{- if .Err }}
+<p class="error">{{ .Err }}</p>
+{{- else }}
+<p>missing {{ .Missing }}, unexpected {{ .Unexpected }}, changed {{ .Changed }}</p>
+<table>
+<thead>
+<tr><th rowspan="2"></th><th class="group" colspan="{{ len .Columns }}">expected</th><th class="separator"></th><th class="group" colspan="{{ len .Columns }}">actual</th></tr>
+<tr>{{ range .Columns }}<th><span class="logical">{{ .Logical }}</span><br>{{ .Name }}</th>{{ end }}<th class="separator"></th>{{ range .Columns }}<th><span class="logical">{{ .Logical }}</span><br>{{ .Name }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr class="{{ .Class }}"><td class="key">{{ .Key }}</td>{{ range .Want }}<td class="want{{ if .Changed }} changed{{ end }}{{ if .Null }} null{{ end }}">{{ .Value }}</td>{{ end }}<td class="separator"></td>{{ range .Got }}<td class="got{{ if .Changed }} changed{{ end }}{{ if .Null }} null{{ end }}">{{ .Value }}</td>{{ end }}</tr>
+{{- end }}
+</tbody>
+</table>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+type htmlReport struct {
+	Title  string
+	Passed int
+	Failed int
+	Tables []htmlTable
+}
+
+type htmlTable struct {
+	ID         string
+	Sheet      string
+	Table      string
+	Pass       bool
+	Err        string
+	Missing    int
+	Unexpected int
+	Changed    int
+	Columns    []htmlColumn
+	Rows       []htmlRow
+}
+
+type htmlColumn struct {
+	Name    string
+	Logical string
+}
+
+type htmlRow struct {
+	Key   string
+	Class string
+	Want  []htmlCell
+	Got   []htmlCell
+}
+
+type htmlCell struct {
+	Value   string
+	Null    bool
+	Changed bool
+}
+
+// writeHTMLReport は比較結果をHTMLレポートとして path に出力します。
+// 全てのシートについて、期待値と実際の値を並べて表示し、差分のあるセルや行を強調表示します。
+func writeHTMLReport(path, title string, res *CompareResult) error {
+	report := htmlReport{Title: title}
+	for i, t := range res.Tables {
+		ht := htmlTable{
+			ID:         fmt.Sprintf("sheet-%d", i+1),
+			Sheet:      t.Sheet,
+			Table:      t.Table,
+			Pass:       t.Equal(),
+			Missing:    len(t.Missing),
+			Unexpected: len(t.Unexpected),
+			Changed:    len(t.Changed),
+		}
+		if ht.Pass {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		if t.Err != nil {
+			ht.Err = t.Err.Error()
+			report.Tables = append(report.Tables, ht)
+			continue
+		}
+
+		for i, c := range t.Columns {
+			hc := htmlColumn{Name: c}
+			if i < len(t.LogicalColumns) {
+				hc.Logical = t.LogicalColumns[i]
+			}
+			ht.Columns = append(ht.Columns, hc)
+		}
+		for _, r := range t.rows {
+			hr := htmlRow{
+				Key:  r.key,
+				Want: htmlCells(r.want, r.changed, len(t.Columns)),
+				Got:  htmlCells(r.got, r.changed, len(t.Columns)),
+			}
+			switch {
+			case r.got == nil:
+				hr.Class = "missing"
+			case r.want == nil:
+				hr.Class = "unexpected"
+			case containsTrue(r.changed):
+				hr.Class = "changed"
+			}
+			ht.Rows = append(ht.Rows, hr)
+		}
+		report.Tables = append(report.Tables, ht)
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, report); err != nil {
+		return fmt.Errorf("exceltesting: render html report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("exceltesting: create directory: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("exceltesting: write html report: %w", err)
+	}
+	return nil
+}
+
+// htmlCells は行の値をHTMLレポートのセルに変換します。
+// 行が存在しない場合は空のセルを返します。
+func htmlCells(values []any, changed []bool, n int) []htmlCell {
+	cells := make([]htmlCell, n)
+	if values == nil {
+		return cells
+	}
+	for i, v := range values {
+		cells[i] = htmlCell{
+			Value:   describeValue(v),
+			Null:    v == nil,
+			Changed: i < len(changed) && changed[i],
+		}
+	}
+	return cells
+}
+
+func containsTrue(bs []bool) bool {
+	for _, b := range bs {
+		if b {
+			return true
+		}
+	}
+	return false
+}
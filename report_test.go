@@ -0,0 +1,57 @@
+package exceltesting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_writeHTMLReport(t *testing.T) {
+	res := &CompareResult{Tables: []TableResult{
+		{
+			Sheet:          "会社",
+			Table:          "company",
+			Columns:        []string{"company_cd", "company_name"},
+			LogicalColumns: []string{"会社コード", "会社名"},
+			Missing:        []RowDiff{{Key: "company_cd=00003", Values: []any{"00003", "C社"}}},
+			Unexpected:     []RowDiff{{Key: "company_cd=00009", Values: []any{"00009", nil}}},
+			Changed:        []CellDiff{{Key: "company_cd=00001", Column: "company_name", Want: "<A社>", Got: "B社"}},
+			rows: []comparedRow{
+				{key: "company_cd=00001", want: []any{"00001", "<A社>"}, got: []any{"00001", "B社"}, changed: []bool{false, true}},
+				{key: "company_cd=00003", want: []any{"00003", "C社"}},
+				{key: "company_cd=00009", got: []any{"00009", nil}},
+			},
+		},
+		{Sheet: "社員", Table: "employee", Columns: []string{"id"}},
+		{Sheet: "部署", Err: errors.New("exceltesting: failed to load excel sheet")},
+	}}
+
+	path := filepath.Join(t.TempDir(), "report", "compare.html")
+	if err := writeHTMLReport(path, "compare.xlsx", res); err != nil {
+		t.Fatalf("writeHTMLReport() error: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+
+	for _, want := range []string{
+		"<title>exceltesting: compare.xlsx</title>",
+		"<p>1 passed, 2 failed</p>",
+		`<span class="logical">会社コード</span><br>company_cd`,
+		`<tr class="changed"><td class="key">company_cd=00001</td>`,
+		`<td class="want changed">&lt;A社&gt;</td>`,
+		`<td class="got changed">B社</td>`,
+		`<tr class="missing">`,
+		`<tr class="unexpected">`,
+		`<td class="got null">NULL</td>`,
+		`<p class="error">exceltesting: failed to load excel sheet</p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("html report should contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package exceltesting
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Reporter は Load、Compare、DumpCSV が失敗を報告する先のインターフェースです。
+//
+// testing.TB (*testing.T, *testing.B, *testing.F) はこのインターフェースを満たします。
+// Ginkgo の GinkgoT() など、他のテストフレームワークからも利用できます。
+// Logf(format string, args ...any) を実装している場合は、期待値を更新したテーブルなどの情報を出力します。
+type Reporter interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+	Cleanup(func())
+}
+
+var _ Reporter = (testing.TB)(nil)
+
+// logf は Reporter が Logf を実装している場合にログを出力します。
+func logf(t Reporter, format string, args ...any) {
+	t.Helper()
+	if l, ok := t.(interface{ Logf(string, ...any) }); ok {
+		l.Logf(format, args...)
+	}
+}
+
+// runSheet はシートごとの処理を実行し、失敗しなかったかどうかを返します。
+// t が *testing.T の場合は t.Run(sheet, ...) のサブテストとして実行し、
+// それ以外の場合は t に報告するメッセージにシート名を付与してそのまま実行します。
+func runSheet(t Reporter, sheet string, f func(t Reporter)) bool {
+	t.Helper()
+	if tt, ok := t.(*testing.T); ok {
+		return tt.Run(sheet, func(t *testing.T) {
+			t.Helper()
+			f(t)
+		})
+	}
+	r := &sheetReporter{Reporter: t, sheet: sheet}
+	f(r)
+	return !r.failed
+}
+
+// sheetReporter は報告するメッセージにシート名を付与する Reporter です。
+type sheetReporter struct {
+	Reporter
+	sheet  string
+	failed bool
+}
+
+func (r *sheetReporter) Errorf(format string, args ...any) {
+	r.Helper()
+	r.failed = true
+	r.Reporter.Errorf("sheet = %s: %s", r.sheet, fmt.Sprintf(format, args...))
+}
+
+func (r *sheetReporter) Fatalf(format string, args ...any) {
+	r.Helper()
+	r.failed = true
+	r.Reporter.Fatalf("sheet = %s: %s", r.sheet, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,56 @@
+package exceltesting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeReporter struct {
+	errors []string
+	fatals []string
+}
+
+func (r *fakeReporter) Helper()        {}
+func (r *fakeReporter) Cleanup(func()) {}
+func (r *fakeReporter) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *fakeReporter) Fatalf(format string, args ...any) {
+	r.fatals = append(r.fatals, fmt.Sprintf(format, args...))
+}
+
+func Test_runSheet(t *testing.T) {
+	r := &fakeReporter{}
+
+	if ok := runSheet(r, "会社", func(t Reporter) {}); !ok {
+		t.Errorf("runSheet() should return true when nothing is reported")
+	}
+	if ok := runSheet(r, "会社", func(t Reporter) { t.Errorf("table(%s) mismatch", "company") }); ok {
+		t.Errorf("runSheet() should return false when an error is reported")
+	}
+	if ok := runSheet(r, "社員", func(t Reporter) { t.Fatalf("load: %v", "failed") }); ok {
+		t.Errorf("runSheet() should return false when a fatal error is reported")
+	}
+
+	if diff := cmp.Diff([]string{"sheet = 会社: table(company) mismatch"}, r.errors); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"sheet = 社員: load: failed"}, r.fatals); diff != "" {
+		t.Errorf("fatals mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_runSheet_subtest(t *testing.T) {
+	var name string
+	ok := runSheet(t, "会社", func(r Reporter) {
+		name = r.(*testing.T).Name()
+	})
+	if !ok {
+		t.Errorf("runSheet() should return true")
+	}
+	if want := t.Name() + "/会社"; name != want {
+		t.Errorf("runSheet() should run %s but %s", want, name)
+	}
+}
@@ -0,0 +1,260 @@
+package exceltesting
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+// CompareResult はExcelブックの期待値とデータベースの値を比較した結果です。
+type CompareResult struct {
+	// シートごとの比較結果。ブックのシートの順序です
+	Tables []TableResult
+}
+
+// Equal は全てのシートで期待値と実際の値が一致している場合に true を返します。
+func (r *CompareResult) Equal() bool {
+	for _, t := range r.Tables {
+		if !t.Equal() {
+			return false
+		}
+	}
+	return true
+}
+
+// Errors は一致しなかったシートごとに、差分を記載したエラーを返します。
+func (r *CompareResult) Errors() []error {
+	var errs []error
+	for _, t := range r.Tables {
+		if t.Err != nil {
+			errs = append(errs, t.Err)
+			continue
+		}
+		if !t.Equal() {
+			errs = append(errs, errors.New(t.String()))
+		}
+	}
+	return errs
+}
+
+// TableResult はシート(テーブル)ごとの比較結果です。
+type TableResult struct {
+	// シート名
+	Sheet string
+	// テーブル名
+	Table string
+	// 比較したカラム
+	Columns []string
+	// 比較したカラムの論理名。Columns と同じ順序です
+	LogicalColumns []string
+	// シートの解析やデータベースからの取得に失敗した場合のエラー
+	Err error
+	// 期待値に記載されているが、テーブルに存在しない行
+	Missing []RowDiff
+	// 期待値に記載されていないが、テーブルに存在する行
+	Unexpected []RowDiff
+	// 期待値と実際の値が異なるセル
+	Changed []CellDiff
+	// 更新モードで、期待値のシートを実際の値で書き換えた場合に true です
+	// 書き換えた場合も Missing, Unexpected, Changed には書き換え前の差分が残ります
+	Updated bool
+
+	// 比較方法
+	mode CompareMode
+	// 比較したカラムの型名(pg_type.typname)。Columns と同じ順序です
+	types []string
+
+	// 突き合わせた行。レポートで期待値と実際の値を並べて表示するために用います
+	rows []comparedRow
+}
+
+// comparedRow は突き合わせた期待値の行と実際の値の行です。
+// 不足している行は got が、想定外の行は want が nil です。
+type comparedRow struct {
+	key     string
+	want    []any
+	got     []any
+	changed []bool
+}
+
+// Equal は期待値と実際の値が一致している場合に true を返します。
+// 更新モードで期待値を書き換えた場合も true を返します。
+func (t TableResult) Equal() bool {
+	return t.Err == nil && (t.Updated || len(t.Missing) == 0 && len(t.Unexpected) == 0 && len(t.Changed) == 0)
+}
+
+// String は差分を1行ずつ記載したメッセージを返します。
+func (t TableResult) String() string {
+	if t.Err != nil {
+		return t.Err.Error()
+	}
+	if t.Updated {
+		return fmt.Sprintf("table(%s) updated, sheet = %s", t.Table, t.Sheet)
+	}
+	if t.Equal() {
+		return fmt.Sprintf("table(%s) equal", t.Table)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "table(%s) mismatch, sheet = %s:", t.Table, t.Sheet)
+	for _, r := range t.Missing {
+		fmt.Fprintf(&b, "\n\trow %s missing%s: %s", r.Key, describeCell(r.Cell), r.describeValues(t.Columns))
+	}
+	for _, r := range t.Unexpected {
+		fmt.Fprintf(&b, "\n\tunexpected row %s: %s", r.Key, r.describeValues(t.Columns))
+	}
+	for _, c := range t.Changed {
+		fmt.Fprintf(&b, "\n\t%s column %s: want %s got %s%s", c.Key, c.Column, describeValue(c.Want), describeValue(c.Got), describeCell(c.Cell))
+	}
+	return b.String()
+}
+
+// RowDiff は過不足のあった行です。
+type RowDiff struct {
+	// 行を識別する値。主キーがある場合は company_cd=00001 のような形式です
+	Key string
+	// 期待値の行の場合は、シート上のA列のセル(例: 会社!A9)。実際の値の行の場合は空です
+	Cell string
+	// 行の値。TableResult.Columns と同じ順序です
+	Values []any
+}
+
+func (r RowDiff) describeValues(columns []string) string {
+	values := make([]string, 0, len(r.Values))
+	for i, v := range r.Values {
+		values = append(values, fmt.Sprintf("%s=%s", columns[i], describeValue(v)))
+	}
+	return strings.Join(values, " | ")
+}
+
+// CellDiff は期待値と実際の値が異なるセルです。
+type CellDiff struct {
+	// 行を識別する値。主キーがある場合は company_cd=00001 のような形式です
+	Key string
+	// カラム名
+	Column string
+	// 期待値。条件による比較の記法を記載した場合は、その記法です
+	Want any
+	// 実際の値
+	Got any
+	// 期待値のシート上のセル(例: 会社!D8)
+	Cell string
+}
+
+// rowDiff は行の値を RowDiff に変換します。
+func (c *comparative) rowDiff(row []x, key, cell string) RowDiff {
+	return RowDiff{Key: key, Cell: cell, Values: values(row)}
+}
+
+func values(row []x) []any {
+	vs := make([]any, 0, len(row))
+	for _, v := range row {
+		vs = append(vs, v.value)
+	}
+	return vs
+}
+
+// describeRow は差分の報告に用いる行を識別する値を返します。
+// キーが存在しない場合は、期待値の行はシート上の行番号、実際の値の行は全カラムの値で識別します。
+func (c *comparative) describeRow(row []x, rowNum int) string {
+	switch {
+	case len(c.keyColumns()) > 0:
+		return describeKey(row, c.key)
+	case rowNum > 0:
+		return fmt.Sprintf("row=%d", rowNum)
+	default:
+		return describeKey(row, c.columns)
+	}
+}
+
+// keyColumns は行を突き合わせるキーのカラムを返します。
+// キーのカラムが比較対象から除外されている場合は、キーで突き合わせできないため nil を返します。
+func (c *comparative) keyColumns() []string {
+	for _, k := range c.key {
+		if !slices.Contains(c.columns, k) {
+			return nil
+		}
+	}
+	return c.key
+}
+
+// matchRows は期待値の各行に対応する実際の値の行のインデックスを返します。対応する行がない場合は -1 です。
+//
+// キーが存在する場合はキーの値が一致する行を対応付けます。キーの値が重複する場合は、全カラムの値が一致する行を優先します。
+// キーが存在しない場合は全カラムの値が一致する行を対応付けるため、重複した行も含めて行の集合として比較します。
+// いずれも1つの実際の値の行を複数の期待値の行に対応付けることはありません。
+func (c *comparative) matchRows() []int {
+	matched := make([]int, len(c.want))
+	used := make([]bool, len(c.got))
+
+	key := c.keyColumns()
+	index := make(map[string][]int)
+	if len(key) > 0 {
+		for i, row := range c.got {
+			k := rowKey(row, key)
+			index[k] = append(index[k], i)
+		}
+	}
+
+	for i, want := range c.want {
+		matched[i] = -1
+		if len(key) > 0 {
+			k := rowKey(want, key)
+			candidates := index[k]
+			if len(candidates) == 0 {
+				continue
+			}
+			// キーが重複する場合は、全カラムの値が一致する行を優先する
+			n := slices.IndexFunc(candidates, func(j int) bool { return c.equalRow(want, c.got[j]) })
+			if n < 0 {
+				n = 0
+			}
+			matched[i] = candidates[n]
+			index[k] = slices.Delete(candidates, n, n+1)
+			continue
+		}
+		for j, got := range c.got {
+			if !used[j] && c.equalRow(want, got) {
+				matched[i] = j
+				used[j] = true
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// describeValue はメッセージに用いる値の文字列表現を返します。
+func describeValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case matcher:
+		return v.String()
+	default:
+		return formatValue(v)
+	}
+}
+
+func describeCell(cell string) string {
+	if cell == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", cell)
+}
+
+// cellName はシート名を含むセルの位置を 会社!D8 のような形式で返します。
+// row が 0 の場合は空文字を返します。
+func cellName(sheet string, col, row int) string {
+	if row == 0 {
+		return ""
+	}
+	name, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s!%s", sheet, name)
+}
@@ -0,0 +1,125 @@
+package exceltesting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTableResult_String(t *testing.T) {
+	tests := []struct {
+		name string
+		in   TableResult
+		want string
+	}{
+		{
+			name: "equal",
+			in:   TableResult{Sheet: "会社", Table: "company"},
+			want: "table(company) equal",
+		},
+		{
+			name: "error",
+			in:   TableResult{Sheet: "会社", Table: "company", Err: errors.New("exceltesting: failed")},
+			want: "exceltesting: failed",
+		},
+		{
+			name: "diff",
+			in: TableResult{
+				Sheet:   "会社",
+				Table:   "company",
+				Columns: []string{"company_cd", "company_name", "revision"},
+				Missing: []RowDiff{
+					{Key: "company_cd=00003", Cell: "会社!A9", Values: []any{"00003", "C社", int64(1)}},
+				},
+				Unexpected: []RowDiff{
+					{Key: "company_cd=00009", Values: []any{"00009", nil, int64(1)}},
+				},
+				Changed: []CellDiff{
+					{Key: "company_cd=00001", Column: "revision", Want: int64(2), Got: int64(3), Cell: "会社!D7"},
+				},
+			},
+			want: "table(company) mismatch, sheet = 会社:\n" +
+				"\trow company_cd=00003 missing (会社!A9): company_cd=00003 | company_name=C社 | revision=1\n" +
+				"\tunexpected row company_cd=00009: company_cd=00009 | company_name=NULL | revision=1\n" +
+				"\tcompany_cd=00001 column revision: want 2 got 3 (会社!D7)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.in.String()); diff != "" {
+				t.Errorf("String() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompareResult_Errors(t *testing.T) {
+	r := &CompareResult{Tables: []TableResult{
+		{Sheet: "会社", Table: "company"},
+		{Sheet: "社員", Table: "employee", Columns: []string{"id"}, Unexpected: []RowDiff{{Key: "id=1", Values: []any{"1"}}}},
+	}}
+	if r.Equal() {
+		t.Errorf("Equal() should be false")
+	}
+	errs := r.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("Errors() should return 1 error but %d: %v", len(errs), errs)
+	}
+	if diff := cmp.Diff("table(employee) mismatch, sheet = 社員:\n\tunexpected row id=1: id=1", errs[0].Error()); diff != "" {
+		t.Errorf("Errors() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_comparative_matchRows(t *testing.T) {
+	row := func(vs ...any) []x {
+		cs := []string{"company_cd", "revision"}
+		r := make([]x, len(vs))
+		for i, v := range vs {
+			r[i] = x{column: cs[i], value: v}
+		}
+		return r
+	}
+	tests := []struct {
+		name string
+		c    comparative
+		want []int
+	}{
+		{
+			name: "match by key",
+			c: comparative{
+				columns: []string{"company_cd", "revision"},
+				key:     []string{"company_cd"},
+				want:    [][]x{row("00001", int64(2)), row("00003", int64(1)), row("00004", int64(1))},
+				got:     [][]x{row("00001", int64(3)), row("00004", int64(1)), row("00009", int64(1))},
+			},
+			want: []int{0, -1, 1},
+		},
+		{
+			name: "key column is ignored",
+			c: comparative{
+				columns: []string{"revision"},
+				key:     []string{"company_cd"},
+				want:    [][]x{{{column: "revision", value: int64(1)}}, {{column: "revision", value: int64(2)}}},
+				got:     [][]x{{{column: "revision", value: int64(2)}}},
+			},
+			want: []int{-1, 0},
+		},
+		{
+			name: "match duplicated rows without key",
+			c: comparative{
+				columns: []string{"company_cd", "revision"},
+				want:    [][]x{row("00001", int64(1)), row("00001", int64(1)), row("00002", int64(1))},
+				got:     [][]x{row("00001", int64(1)), row("00002", int64(1))},
+			},
+			want: []int{0, -1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.c.matchRows()); diff != "" {
+				t.Errorf("matchRows() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
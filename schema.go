@@ -0,0 +1,151 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// IsolateSchema は sourceSchema のテーブル定義を複製した、テストごとのスキーマを作成します。
+//
+// 戻り値の *sql.Conn は search_path を作成したスキーマ、sourceSchema の順に設定しているため、
+// New(conn) で作成した構造体の Load、Compare は作成したスキーマのテーブルを対象にします。
+// テストごとにテーブルが分かれるため、t.Parallel() で並列に実行できます。
+// 作成したスキーマと接続は t.Cleanup で破棄します。
+//
+// テーブルは CREATE TABLE ... (LIKE ... INCLUDING ALL) で複製するため、カラムの定義、デフォルト値、
+// CHECK 制約、インデックス(主キー、一意制約を含む)、identity 列を複製します。
+// パーティションテーブルはパーティションも複製し、serial 型のカラムのシーケンスはスキーマごとに作成します。
+// 外部キー制約、トリガー、ビューは複製しません。
+func IsolateSchema(t Reporter, db *sql.DB, sourceSchema string) *sql.Conn {
+	t.Helper()
+	ctx := context.Background()
+
+	schema, err := uniqueName("exceltesting")
+	if err != nil {
+		t.Fatalf("exceltesting: %v", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("exceltesting: get connection: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE;`, quoteIdent(schema))); err != nil {
+			t.Errorf("exceltesting: drop schema %s: %v", schema, err)
+		}
+	})
+
+	if err := CloneSchema(ctx, conn, sourceSchema, schema); err != nil {
+		t.Fatalf("exceltesting: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`SET search_path TO %s, %s;`, quoteIdent(schema), quoteIdent(sourceSchema))); err != nil {
+		t.Fatalf("exceltesting: set search_path: %v", err)
+	}
+	return conn
+}
+
+// CloneSchema は sourceSchema のテーブル定義を複製したスキーマ targetSchema を作成します。
+// データは複製しません。複製する対象は IsolateSchema を参照してください。
+func CloneSchema(ctx context.Context, db DB, sourceSchema, targetSchema string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	src, dst := quoteIdent(sourceSchema), quoteIdent(targetSchema)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA %s;`, dst)); err != nil {
+		return fmt.Errorf("create schema %s: %w", targetSchema, err)
+	}
+
+	ddls, err := cloneTableDDLs(ctx, tx, sourceSchema, src, dst)
+	if err != nil {
+		return err
+	}
+	seqs, err := cloneSequenceDDLs(ctx, tx, sourceSchema, dst)
+	if err != nil {
+		return err
+	}
+	for _, ddl := range append(ddls, seqs...) {
+		if _, err := tx.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("clone schema %s: %s: %w", sourceSchema, ddl, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// cloneTableDDLs はテーブルを複製する DDL を作成します。
+func cloneTableDDLs(ctx context.Context, tx *sql.Tx, sourceSchema, src, dst string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, getCloneTablesQuery, sourceSchema)
+	if err != nil {
+		return nil, fmt.Errorf("get tables of %s: %w", sourceSchema, err)
+	}
+	defer rows.Close()
+
+	var ddls []string
+	for rows.Next() {
+		var name, kind, partitionKey, parent, bound string
+		if err := rows.Scan(&name, &kind, &partitionKey, &parent, &bound); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+
+		var ddl string
+		if parent != "" {
+			// パーティションの制約やインデックスは親テーブルから引き継ぐ
+			ddl = fmt.Sprintf(`CREATE TABLE %s.%s PARTITION OF %s.%s %s`, dst, quoteIdent(name), dst, quoteIdent(parent), bound)
+		} else {
+			ddl = fmt.Sprintf(`CREATE TABLE %s.%s (LIKE %s.%s INCLUDING ALL)`, dst, quoteIdent(name), src, quoteIdent(name))
+		}
+		if kind == "p" {
+			ddl += " PARTITION BY " + partitionKey
+		}
+		ddls = append(ddls, ddl+";")
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return ddls, nil
+}
+
+// cloneSequenceDDLs は serial 型のカラムのシーケンスを複製先のスキーマに作成し、カラムのデフォルト値を置き換える DDL を作成します。
+func cloneSequenceDDLs(ctx context.Context, tx *sql.Tx, sourceSchema, dst string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, getCloneSequencesQuery, sourceSchema)
+	if err != nil {
+		return nil, fmt.Errorf("get sequences of %s: %w", sourceSchema, err)
+	}
+	defer rows.Close()
+
+	var ddls []string
+	for rows.Next() {
+		var table, column, sequence string
+		if err := rows.Scan(&table, &column, &sequence); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		seq := dst + "." + quoteIdent(sequence)
+		ddls = append(ddls,
+			fmt.Sprintf(`CREATE SEQUENCE %s OWNED BY %s.%s.%s;`, seq, dst, quoteIdent(table), quoteIdent(column)),
+			fmt.Sprintf(`ALTER TABLE %s.%s ALTER COLUMN %s SET DEFAULT nextval('%s');`, dst, quoteIdent(table), quoteIdent(column), strings.ReplaceAll(seq, "'", "''")),
+		)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return ddls, nil
+}
+
+// quoteIdent はSQLの識別子を二重引用符で囲みます。
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral はSQLの文字列を単一引用符で囲みます。
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
@@ -0,0 +1,57 @@
+package exceltesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/future-architect/go-exceltesting/testonly"
+)
+
+func TestIsolateSchema(t *testing.T) {
+	conn := testonly.OpenTestDB(t)
+	t.Cleanup(func() { conn.Close() })
+
+	testonly.ExecSQLFile(t, conn, filepath.Join("testdata", "schema", "ddl.sql"))
+
+	// 並列に実行するサブテストの終了を待つ
+	t.Run("parallel", func(t *testing.T) {
+		for _, name := range []string{"a", "b"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				c := IsolateSchema(t, conn, "public")
+				e := New(c)
+				e.Load(t, LoadRequest{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-"})
+
+				var got int
+				if err := c.QueryRowContext(context.Background(), `SELECT count(*) FROM test_x;`).Scan(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got != 1 {
+					t.Errorf("isolated test_x should have 1 row but %d", got)
+				}
+			})
+		}
+	})
+
+	var got int
+	if err := conn.QueryRow(`SELECT count(*) FROM public.test_x;`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != 0 {
+		t.Errorf("public.test_x should not be loaded but has %d rows", got)
+	}
+}
+
+func Test_quoteIdent(t *testing.T) {
+	if got, want := quoteIdent(`company"s`), `"company""s"`; got != want {
+		t.Errorf("quoteIdent() = %s, want %s", got, want)
+	}
+}
+
+func Test_quoteLiteral(t *testing.T) {
+	if got, want := quoteLiteral(`company's`), `'company''s'`; got != want {
+		t.Errorf("quoteLiteral() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package exceltesting
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/future-architect/go-exceltesting/internal/sqlscript"
+)
+
+// Statement はSQLスクリプトに記載された1つの文と、その開始位置の行番号です。
+type Statement = sqlscript.Statement
+
+// SplitSQL はSQLスクリプトをセミコロンで文に分割します。
+// 文字列リテラル、引用符で囲んだ識別子、ドル引用符で囲んだ関数の本体、コメントの中のセミコロンでは分割しません。
+func SplitSQL(script string) ([]Statement, error) {
+	stmts, err := sqlscript.Split(script)
+	if err != nil {
+		return nil, fmt.Errorf("exceltesting: %w", err)
+	}
+	return stmts, nil
+}
+
+// ExecSQL はDDLやDMLを記載したSQLスクリプトを文に分割し、1つのトランザクションで順に実行します。
+// 失敗した場合は失敗した文の開始位置の行番号をエラーに含めます。
+// CREATE DATABASE や VACUUM などトランザクション内で実行できない文は実行できません。
+func ExecSQL(ctx context.Context, db DB, script string) error {
+	if err := sqlscript.Exec(ctx, db, "script", script); err != nil {
+		return fmt.Errorf("exceltesting: %w", err)
+	}
+	return nil
+}
+
+// ExecSQLFile はSQLファイルを ExecSQL と同じく実行します。失敗した場合はファイル名と行番号をエラーに含めます。
+func ExecSQLFile(ctx context.Context, db DB, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("exceltesting: read file: %w", err)
+	}
+	if err := sqlscript.Exec(ctx, db, path, string(b)); err != nil {
+		return fmt.Errorf("exceltesting: %w", err)
+	}
+	return nil
+}
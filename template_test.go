@@ -0,0 +1,158 @@
+package exceltesting
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/future-architect/go-exceltesting/testonly"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTemplateDB_OpenTestDB(t *testing.T) {
+	tmpl := &TemplateDB{
+		Open:        testonly.OpenDB,
+		Name:        "exceltesting_template_test",
+		SchemaFiles: []string{filepath.Join("testdata", "schema", "ddl.sql")},
+		Books:       []LoadRequest{{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-"}},
+	}
+
+	// テンプレートのデータは作成したデータベースごとに独立している
+	db1 := tmpl.OpenTestDB(t)
+	db2 := tmpl.OpenTestDB(t)
+	if _, err := db1.Exec(`DELETE FROM test_x;`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		db   *sql.DB
+		want int
+	}{
+		{name: "deleted", db: db1, want: 0},
+		{name: "template", db: db2, want: 1},
+	}
+	for _, tt := range tests {
+		var got int
+		if err := tt.db.QueryRow(`SELECT count(*) FROM test_x;`).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: test_x should have %d rows but %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestTemplateDB_OpenTestDB_sameName(t *testing.T) {
+	schema := []string{filepath.Join("testdata", "schema", "ddl.sql")}
+	withBook := &TemplateDB{
+		Open:        testonly.OpenDB,
+		Name:        "exceltesting_template_same",
+		SchemaFiles: schema,
+		Books:       []LoadRequest{{TargetBookPath: filepath.Join("testdata", "load.xlsx"), SheetPrefix: "normal-"}},
+	}
+	withoutBook := &TemplateDB{Open: testonly.OpenDB, Name: "exceltesting_template_same", SchemaFiles: schema}
+
+	// 同じ Name でもDDLやブックが異なる場合は、それぞれのテンプレートデータベースから作成する
+	tests := []struct {
+		name string
+		tmpl *TemplateDB
+		want int
+	}{
+		{name: "with book", tmpl: withBook, want: 1},
+		{name: "without book", tmpl: withoutBook, want: 0},
+		{name: "with book again", tmpl: withBook, want: 1},
+	}
+	for _, tt := range tests {
+		db := tt.tmpl.OpenTestDB(t)
+		var got int
+		if err := db.QueryRow(`SELECT count(*) FROM test_x;`).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: test_x should have %d rows but %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestTemplateDB_DropStaleTemplates(t *testing.T) {
+	ctx := context.Background()
+	ddl := filepath.Join(t.TempDir(), "ddl.sql")
+	if err := os.WriteFile(ddl, []byte("CREATE TABLE a (id int);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := &TemplateDB{Open: testonly.OpenDB, Name: "exceltesting_template_stale", SchemaFiles: []string{ddl}}
+	if err := old.Build(ctx); err != nil {
+		t.Fatal(err)
+	}
+	oldName, _, err := old.templateName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DDLを変更すると別のテンプレートデータベースを作成し、古いものは削除できる
+	if err := os.WriteFile(ddl, []byte("CREATE TABLE a (id bigint);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	current := &TemplateDB{Open: testonly.OpenDB, Name: "exceltesting_template_stale", SchemaFiles: []string{ddl}}
+	db := current.OpenTestDB(t)
+
+	dropped, err := current.DropStaleTemplates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{oldName}, dropped); diff != "" {
+		t.Errorf("DropStaleTemplates() mismatch (-want +got):\n%s", diff)
+	}
+
+	// 現在のテンプレートデータベースと、作成したテスト用のデータベースは削除しない
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM pg_database WHERE datname IN ($1, $2, current_database());`, oldName, current.template).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("DropStaleTemplates() should drop only %s but %d of 3 databases remain", oldName, n)
+	}
+	if dropped, err := current.DropStaleTemplates(ctx); err != nil || len(dropped) != 0 {
+		t.Errorf("DropStaleTemplates() again = %v, %v, want nothing dropped", dropped, err)
+	}
+}
+
+func TestTemplateDB_checksum(t *testing.T) {
+	dir := t.TempDir()
+	ddl := filepath.Join(dir, "ddl.sql")
+	if err := os.WriteFile(ddl, []byte("CREATE TABLE a (id int);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	book := filepath.Join("testdata", "load.xlsx")
+
+	tmpl := &TemplateDB{SchemaFiles: []string{ddl}, Books: []LoadRequest{{TargetBookPath: book}}}
+	before, err := tmpl.checksum()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// FS から読み込んだ同じブックは同じハッシュ値になる
+	fsTmpl := &TemplateDB{SchemaFiles: []string{ddl}, Books: []LoadRequest{{TargetBookPath: "load.xlsx", TargetBookFS: os.DirFS("testdata")}}}
+	if got, err := fsTmpl.checksum(); err != nil || got != before {
+		t.Errorf("checksum() of the same book from fs.FS = %s, %v, want %s", got, err, before)
+	}
+
+	prefixed := &TemplateDB{SchemaFiles: []string{ddl}, Books: []LoadRequest{{TargetBookPath: book, SheetPrefix: "normal-"}}}
+	if got, _ := prefixed.checksum(); got == before {
+		t.Errorf("checksum() should change when the load request changes")
+	}
+
+	if err := os.WriteFile(ddl, []byte("CREATE TABLE a (id bigint);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tmpl.checksum(); got == before {
+		t.Errorf("checksum() should change when the schema file changes")
+	}
+
+	if _, err := (&TemplateDB{Books: []LoadRequest{{TargetBookReader: os.Stdin}}}).checksum(); err == nil {
+		t.Errorf("checksum() should return error for a book from io.Reader")
+	}
+}
@@ -1,3 +1,5 @@
+DROP VIEW IF EXISTS company_view
+;
 DROP TABLE IF EXISTS company
 ;
 CREATE TABLE company(
@@ -11,6 +13,18 @@ CREATE TABLE company(
 )
 ;
 
+CREATE VIEW company_view AS SELECT company_cd, company_name FROM company
+;
+
+DROP TABLE IF EXISTS company_log
+;
+CREATE TABLE company_log(
+    company_cd varchar(5) NOT NULL,
+    message varchar(256) NOT NULL,
+    logged_at timestamp with time zone NOT NULL
+)
+;
+
 DROP TABLE IF EXISTS test_x
 ;
 CREATE TABLE test_x(
@@ -56,3 +70,7 @@ DROP TABLE IF EXISTS temperature_2021_2022
 ;
 CREATE TABLE temperature_2021_2022 PARTITION OF temperature FOR VALUES FROM ('20210101') TO ('20220101')
 ;
+DROP TABLE IF EXISTS temperature_2022_2023
+;
+CREATE TABLE temperature_2022_2023 PARTITION OF temperature FOR VALUES FROM ('20220101') TO ('20230101')
+;
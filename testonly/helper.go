@@ -1,29 +1,41 @@
 package testonly
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
-	"strings"
 	"testing"
 
+	"github.com/future-architect/go-exceltesting/internal/sqlscript"
+
 	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
-func OpenTestDB(t *testing.T) *sql.DB {
-	t.Helper()
+const (
+	DBUser = "excellocal"
+	DBPass = "password"
+	DBHost = "localhost"
+	DBPort = "15432"
+	DBName = "excellocal"
+)
 
-	const (
-		DBUser = "excellocal"
-		DBPass = "password"
-		DBHost = "localhost"
-		DBPort = "15432"
-		DBName = "excellocal"
-	)
+// DSN はテスト用のデータベースの接続文字列です
+var DSN = fmt.Sprintf("postgres://%s:%s@%s:%s/%s", DBUser, DBPass, DBHost, DBPort, DBName)
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", DBUser, DBPass, DBHost, DBPort, DBName)
+// OpenDB はテスト用のデータベースサーバーの database に接続します。database が空の場合は DBName に接続します。
+// exceltesting.TemplateDB の Open に指定します。
+func OpenDB(database string) (*sql.DB, error) {
+	if database == "" {
+		database = DBName
+	}
+	return sql.Open("pgx", fmt.Sprintf("postgres://%s:%s@%s:%s/%s", DBUser, DBPass, DBHost, DBPort, database))
+}
 
-	db, err := sql.Open("pgx", dsn)
+func OpenTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("pgx", DSN)
 	if err != nil {
 		t.Fatalf("failed to open db: %v", err)
 	}
@@ -31,6 +43,9 @@ func OpenTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
+// ExecSQLFile はSQLファイルを実行します。
+// 文字列リテラルやドル引用符で囲んだ関数の本体の中のセミコロンでは分割しません。
+// テスト以外からは exceltesting.ExecSQLFile を利用してください。
 func ExecSQLFile(t *testing.T, db *sql.DB, filePath string) {
 	t.Helper()
 
@@ -39,25 +54,7 @@ func ExecSQLFile(t *testing.T, db *sql.DB, filePath string) {
 		t.Fatalf("failed to read file: %v", err)
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		t.Fatalf("failed to start transaction: %v", err)
-	}
-	defer tx.Rollback()
-
-	queries := strings.Split(string(b), ";")
-	for _, query := range queries {
-
-		q := strings.TrimSpace(query)
-		if q == "" {
-			continue
-		}
-		if _, err = tx.Exec(q); err != nil {
-			t.Fatalf("failed to exec sql, query = %s: %v", q, err)
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("failed to commit: %v", err)
+	if err := sqlscript.Exec(context.Background(), db, filePath, string(b)); err != nil {
+		t.Fatalf("failed to exec sql file: %v", err)
 	}
 }
@@ -0,0 +1,69 @@
+package exceltesting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// textconvEscaper はセル内の改行やタブを1行に収めるためのエスケープです
+var textconvEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// Textconv はExcelブックの全シートを行指向のテキストに変換して w に書き出します。
+//
+// 出力はシートごとにテーブル名、カラム、A列の値を付与したデータ行を1行ずつ並べたもので、
+// git の textconv に設定することでExcelファイルの差分を git diff で確認できます。
+// exceltesting形式として解析できないシートは、その理由のみ出力します。
+func Textconv(w io.Writer, path string) error {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("exceltesing: excelize.OpenFile: %w", err)
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(w)
+	for i, sheetName := range f.GetSheetList() {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		writeSheetText(bw, f, sheetName)
+	}
+	return bw.Flush()
+}
+
+func writeSheetText(w io.Writer, f *excelize.File, sheetName string) {
+	fmt.Fprintf(w, "sheet: %s\n", textconvEscaper.Replace(sheetName))
+
+	s, err := parseSheet(f, sheetName)
+	if err != nil {
+		fmt.Fprintf(w, "skipped: %s\n", textconvEscaper.Replace(err.Error()))
+		return
+	}
+
+	fmt.Fprintf(w, "table: %s", textconvEscaper.Replace(s.table.name))
+	if s.logicalName != "" {
+		fmt.Fprintf(w, " (%s)", textconvEscaper.Replace(s.logicalName))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "version: %s\n", s.version)
+
+	columns := make([]string, 0, len(s.table.columns))
+	for i, c := range s.table.columns {
+		if i < len(s.logicalColumns) && s.logicalColumns[i] != "" && s.logicalColumns[i] != c {
+			c = fmt.Sprintf("%s (%s)", c, s.logicalColumns[i])
+		}
+		columns = append(columns, textconvEscaper.Replace(c))
+	}
+	fmt.Fprintf(w, "columns: %s\n", strings.Join(columns, ", "))
+
+	for i, row := range s.table.data {
+		cells := make([]string, 0, len(row))
+		for j, cell := range row {
+			cells = append(cells, fmt.Sprintf("%s=%s", s.table.columns[j], textconvEscaper.Replace(cell)))
+		}
+		fmt.Fprintf(w, "row %s: %s\n", textconvEscaper.Replace(s.labels[i]), strings.Join(cells, " | "))
+	}
+}
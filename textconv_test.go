@@ -0,0 +1,73 @@
+package exceltesting
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTextconv(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "multiple sheets",
+			path: filepath.Join("testdata", "dumpWithEmptyFileMultipleSheets.xlsx"),
+			want: `sheet: 会社1
+table: company (会社)
+version: 1.0
+columns: company_cd (会社コード), company_name (会社名), founded_year (創業年), created_at (作成日時), updated_at (更新日時), revision (リビジョン)
+row 1: company_cd=00001 | company_name=Future | founded_year=1989 | created_at=current_timestamp | updated_at=current_timestamp | revision=1
+row 2: company_cd=00002 | company_name=YDC | founded_year=1972 | created_at=current_timestamp | updated_at=current_timestamp | revision=1
+
+sheet: 会社 2
+table: company (会社)
+version: 1.0
+columns: company_cd (会社コード), company_name (会社名), founded_year (創業年), created_at (作成日時), updated_at (更新日時), revision (リビジョン)
+
+sheet: 会社3
+table: company (会社)
+version: 1.0
+columns: company_cd (会社コード), company_name (会社名), founded_year (創業年), created_at (作成日時), updated_at (更新日時), revision (リビジョン)
+row 1: company_cd=00001 | company_name=Future | founded_year=1989 | created_at=current_timestamp | updated_at=current_timestamp | revision=1
+`,
+		},
+		{
+			name: "sheet format version 2.0",
+			path: filepath.Join("testdata", "load_v2.xlsx"),
+			want: `sheet: normal-テストx
+table: test_x (テストx)
+version: 2.0
+columns: id, a, b, c, d, e, f, g, h, i, j, k, l, m, n, o, p, q, s, t, u, v, z
+row 1: id=test1 | a=true | b=bytea | c=a | d=2022-01-01 | e=0.1 | f=0.01 | g={} | h={} | i=0.0.0.0 | j=32767 | k=2147483647 | l=9223372036854775807 | m=1 | n=11111 | o=0 | p=test | q=01:02:03 | s=2022-01-01 01:02:03 | t=2022-01-01 01:02:03+09 | u=cee0db76-d69c-4ae3-ae33-5b5970adde48 | v=abc | z=1
+
+sheet: option-テストx
+table: test_x (テストx)
+version: 1.0
+columns: id
+row 1: id=test-opt
+`,
+		},
+		{
+			name:    "file not found",
+			path:    filepath.Join("testdata", "not_found.xlsx"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Textconv(&buf, tt.path); (err != nil) != tt.wantErr {
+				t.Fatalf("Textconv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Textconv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
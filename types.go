@@ -43,3 +43,19 @@ func defaultValueFromDBType(dbType string) string {
 	}
 	return ""
 }
+
+// formatValue はデータベースから取得した値を文字列表現に変換します。
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999Z07:00")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,158 @@
+package exceltesting
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	updateFlagOnce sync.Once
+	updateFlag     *bool
+)
+
+// UpdateFlag は go test の -exceltesting.update フラグを登録し、指定されたかどうかを返します。
+// ライブラリを利用するバイナリにフラグを追加しないよう、利用するテストパッケージで明示的に呼び出します。
+// 複数回呼び出した場合は同じフラグを返します。
+//
+//	var update = exceltesting.UpdateFlag()
+//
+//	func TestX(t *testing.T) {
+//		e.Compare(t, exceltesting.CompareRequest{TargetBookPath: "testdata/want.xlsx", Update: *update})
+//	}
+//
+//	go test ./... -args -exceltesting.update
+func UpdateFlag() *bool {
+	updateFlagOnce.Do(func() {
+		updateFlag = flag.Bool("exceltesting.update", false, "update expected sheets of Compare with the actual database rows")
+	})
+	return updateFlag
+}
+
+// updateEnabled は期待値を更新するかどうかを返します。
+func updateEnabled(r *CompareRequest) bool {
+	return r.Update
+}
+
+// updateBook は比較結果が一致しなかったシートのデータ行を、データベースの値で書き換えて保存します。
+//
+// 値が異なるセルは実際の値に書き換え、テーブルに存在しない行は削除し、期待値に記載されていない行は末尾に追記します。
+// ヘッダ行やセルのスタイル、値が一致したセル(条件による比較の記法を含む)、比較から除外したカラム、他のシートはそのまま残します。
+// 追記した行は最後のデータ行のスタイルを引き継ぎます。
+// CompareModeAbsent のシートは期待値を実際の値から決められないため更新しません。
+// 更新したシートは TableResult.Updated が true になります。
+func updateBook(path string, res *CompareResult) error {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("exceltesting: failed to open excel file: %w", err)
+	}
+	defer f.Close()
+
+	var updated bool
+	for i := range res.Tables {
+		t := &res.Tables[i]
+		if t.Equal() || !t.updatable() {
+			continue
+		}
+		if err := updateSheet(f, t); err != nil {
+			return fmt.Errorf("exceltesting: update sheet, sheet = %s: %w", t.Sheet, err)
+		}
+		t.Updated = true
+		updated = true
+	}
+
+	if !updated {
+		return nil
+	}
+	if err := f.Save(); err != nil {
+		return fmt.Errorf("exceltesting: save excel file: %w", err)
+	}
+	return nil
+}
+
+// updatable は期待値を実際の値で更新できるかどうかを返します。
+func (t *TableResult) updatable() bool {
+	return t.Err == nil && t.mode != CompareModeAbsent
+}
+
+func updateSheet(f *excelize.File, t *TableResult) error {
+	s, err := parseSheet(f, t.Sheet)
+	if err != nil {
+		return err
+	}
+
+	// 行の削除で行番号がずれないよう、追記、書き換え、削除の順に行う
+	for i, r := range t.Unexpected {
+		var row int
+		if len(s.rowNums) > 0 {
+			last := s.rowNums[len(s.rowNums)-1]
+			row = last + i + 1
+			if err := f.DuplicateRowTo(t.Sheet, last, row); err != nil {
+				return fmt.Errorf("duplicate row: %w", err)
+			}
+		} else {
+			row = s.columnDefineRowNum + i + 1
+		}
+
+		values := make([]any, len(s.table.columns)+1)
+		values[0] = strconv.Itoa(len(s.rowNums) + i + 1)
+		for j, v := range r.Values {
+			if idx := slices.Index(s.table.columns, t.Columns[j]); idx >= 0 {
+				values[idx+1] = sheetValue(v, t.columnType(j))
+			}
+		}
+		if err := f.SetSheetRow(t.Sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+			return fmt.Errorf("set sheet row: %w", err)
+		}
+	}
+
+	for _, c := range t.Changed {
+		if c.Cell == "" {
+			continue
+		}
+		if err := f.SetCellValue(t.Sheet, cellOf(c.Cell), sheetValue(c.Got, t.columnType(slices.Index(t.Columns, c.Column)))); err != nil {
+			return fmt.Errorf("set cell value: %w", err)
+		}
+	}
+
+	var removes []int
+	for _, r := range t.Missing {
+		if r.Cell == "" {
+			continue
+		}
+		_, row, err := excelize.CellNameToCoordinates(cellOf(r.Cell))
+		if err != nil {
+			return fmt.Errorf("cell name: %w", err)
+		}
+		removes = append(removes, row)
+	}
+	slices.Sort(removes)
+	for i := len(removes) - 1; i >= 0; i-- {
+		if err := f.RemoveRow(t.Sheet, removes[i]); err != nil {
+			return fmt.Errorf("remove row: %w", err)
+		}
+	}
+	return nil
+}
+
+// columnType は j 番目のカラムの型名を返します。型が不明な場合は空文字を返します。
+func (t *TableResult) columnType(j int) string {
+	if j < 0 || j >= len(t.types) {
+		return ""
+	}
+	return t.types[j]
+}
+
+// sheetValue はデータベースの値を、期待値のセルに記載する文字列表現に変換します。
+// bytea はバイト列をそのまま記載せず、データベースの出力と同じ16進数形式(\x0102)で記載します。
+func sheetValue(v any, typ string) string {
+	if b, ok := v.([]byte); ok && typ == "bytea" {
+		return `\x` + hex.EncodeToString(b)
+	}
+	return formatValue(v)
+}
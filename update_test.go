@@ -0,0 +1,172 @@
+package exceltesting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_updateBook(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "compare_matcher.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "compare_matcher.xlsx")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &CompareResult{Tables: []TableResult{{
+		Sheet:   "会社",
+		Table:   "company",
+		Columns: []string{"company_cd", "company_name", "founded_year", "revision"},
+		Missing: []RowDiff{{Key: "company_cd=00001", Cell: "会社!A7", Values: []any{"00001", nil, nil, nil}}},
+		Unexpected: []RowDiff{
+			{Key: "company_cd=00003", Values: []any{"00003", "Future", int64(1989), int64(1)}},
+		},
+		Changed: []CellDiff{
+			{Key: "company_cd=00002", Column: "revision", Want: "1", Got: int64(2), Cell: "会社!G8"},
+		},
+	}}}
+	if err := updateBook(path, res); err != nil {
+		t.Fatalf("updateBook() error: %v", err)
+	}
+	if !res.Tables[0].Updated || !res.Equal() {
+		t.Errorf("updated table should be equal: %+v", res.Tables[0])
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := f.GetRows("会社")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"会社"},
+		{"company"},
+		{"version", "2.0"},
+		nil,
+		{"項目名", "会社コード", "会社名", "創業年", "作成日時", "更新日時", "リビジョン"},
+		{"項目物理名", "company_cd", "company_name", "founded_year", "created_at", "updated_at", "revision"},
+		{"2", "00002", "YDC", "1972", "<NOW±1m>", "<NOT NULL>", "2"},
+		{"3", "00003", "Future", "1989", "", "", "1"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("updated sheet mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_updateBook_absent(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "compare_matcher.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "compare_matcher.xlsx")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &CompareResult{Tables: []TableResult{{
+		Sheet:      "会社",
+		Table:      "company",
+		Columns:    []string{"company_cd"},
+		Unexpected: []RowDiff{{Key: "company_cd=00001", Values: []any{"00001"}}},
+		mode:       CompareModeAbsent,
+	}}}
+	if err := updateBook(path, res); err != nil {
+		t.Fatalf("updateBook() error: %v", err)
+	}
+	if res.Tables[0].Updated || res.Equal() {
+		t.Errorf("absent mode table should not be updated")
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(b, after) {
+		t.Errorf("book should not be modified")
+	}
+}
+
+func Test_sheetValue(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		typ  string
+		want string
+	}{
+		{name: "bytea", v: []byte{0x00, '\\', 0xff}, typ: "bytea", want: `\x005cff`},
+		{name: "json", v: []byte(`{"a":1}`), typ: "json", want: `{"a":1}`},
+		{name: "unknown type", v: int64(1), typ: "", want: "1"},
+		{name: "null", v: nil, typ: "bytea", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sheetValue(tt.v, tt.typ); got != tt.want {
+				t.Errorf("sheetValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_exceltesing_finishCompare_diffBookBeforeUpdate(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "compare_matcher.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compare_matcher.xlsx")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := CompareRequest{TargetBookPath: path, DiffBookPath: filepath.Join(dir, "diff.xlsx"), Update: true}
+
+	wb, err := openWorkbook(r.bookSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wb.Close()
+
+	// 更新できないシートがあるため、更新後も差分ブックを出力する
+	res := &CompareResult{Tables: []TableResult{
+		{
+			Sheet:   "会社",
+			Table:   "company",
+			Columns: []string{"company_cd", "company_name", "founded_year", "revision"},
+			Changed: []CellDiff{{Key: "company_cd=00002", Column: "revision", Want: "1", Got: int64(2), Cell: "会社!G8"}},
+		},
+		{Sheet: "会社", Table: "company", Err: errors.New("failed")},
+	}}
+	e := &exceltesing{}
+	if err := e.finishCompare(wb, &r, res); err != nil {
+		t.Fatalf("finishCompare() error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{path: r.TargetBookPath, want: "2"},
+		{path: r.DiffBookPath, want: "1"},
+	} {
+		f, err := excelize.OpenFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.GetCellValue("会社", "G8")
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s G8 = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}